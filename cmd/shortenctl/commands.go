@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+	"url-shortener/internal/model"
+	"url-shortener/internal/repository"
+	"url-shortener/internal/service"
+)
+
+// errStopScan прерывает обход хранилища, когда набран лимит записей.
+var errStopScan = errors.New("stop scan")
+
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: shortenctl %s %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func runList(ctx context.Context, c *cli, args []string) error {
+	fs := newFlagSet("list", "[flags]")
+	withDeleted := fs.Bool("deleted", false, "Include deleted links")
+	userID := fs.String("user", "", "Only links of this user")
+	after := fs.String("after", "", "Continue after this cursor")
+	limit := fs.Int("limit", 0, "Maximum number of links (0 for all)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	admin, err := c.admin()
+	if err != nil {
+		return err
+	}
+
+	var (
+		urls []*model.URL
+		next string
+	)
+	err = admin.Scan(ctx, *after, func(url *model.URL, cursor string) error {
+		if url.Deleted && !*withDeleted || *userID != "" && url.UserID != *userID {
+			return nil
+		}
+		if *limit > 0 && len(urls) == *limit {
+			return errStopScan
+		}
+		urls = append(urls, url)
+		next = cursor
+		return nil
+	})
+	switch {
+	case errors.Is(err, errStopScan):
+		if err := c.out.URLs(urls); err != nil {
+			return err
+		}
+		c.out.Note("more links available, continue with -after %s", next)
+		return nil
+	case err != nil:
+		return err
+	default:
+		return c.out.URLs(urls)
+	}
+}
+
+func runGet(ctx context.Context, c *cli, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: shortenctl get ID...")
+	}
+	urls := make([]*model.URL, 0, len(args))
+	var missing []string
+	for _, id := range args {
+		url, err := c.repo.FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if url == nil {
+			missing = append(missing, id)
+			continue
+		}
+		urls = append(urls, url)
+	}
+	if err := c.out.URLs(urls); err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("links not found: %v", missing)
+	}
+	return nil
+}
+
+func runFind(ctx context.Context, c *cli, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: shortenctl find URL")
+	}
+	url, err := c.repo.FindByOriginalURL(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if url == nil {
+		return fmt.Errorf("link for %s not found", args[0])
+	}
+	return c.out.URLs([]*model.URL{url})
+}
+
+// runCreate сокращает URL через сервис, чтобы алиас и срок действия проверялись так же, как в API.
+func runCreate(ctx context.Context, c *cli, args []string) (err error) {
+	fs := newFlagSet("create", "[flags] URL")
+	var opts service.ShortenOptions
+	fs.StringVar(&opts.Alias, "alias", "", "Short link ID instead of a random one")
+	fs.StringVar(&opts.UserID, "user", "", "Owner user ID")
+	fs.DurationVar(&opts.TTL, "ttl", 0, "Link lifetime")
+	expiresAt := fs.String("expires-at", "", "Link expiry time in RFC 3339 format")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if *expiresAt != "" {
+		t, err := time.Parse(time.RFC3339, *expiresAt)
+		if err != nil {
+			return fmt.Errorf("invalid expires-at: %w", err)
+		}
+		opts.ExpiresAt = &t
+	}
+
+	svc := service.NewURLService(c.repo, c.cfg.BaseURL)
+	defer func() {
+		if shutdownErr := svc.Shutdown(context.Background()); shutdownErr != nil && err == nil {
+			err = shutdownErr
+		}
+	}()
+
+	url, err := svc.ShortenURL(ctx, fs.Arg(0), opts)
+	var conflict *repository.ErrConflict
+	if errors.As(err, &conflict) {
+		if printErr := c.out.URLs([]*model.URL{conflict.URL}); printErr != nil {
+			return printErr
+		}
+		return fmt.Errorf("URL is already shortened as %s", conflict.URL.ID)
+	}
+	if err != nil {
+		return err
+	}
+	return c.out.URLs([]*model.URL{url})
+}
+
+// runDelete по умолчанию помечает ссылки удаленными, как это делает владелец через API:
+// переход по ним вернет 410. С -purge записи удаляются из хранилища совсем.
+func runDelete(ctx context.Context, c *cli, args []string) error {
+	fs := newFlagSet("delete", "[flags] ID...")
+	purge := fs.Bool("purge", false, "Remove links permanently")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	if *purge {
+		admin, err := c.admin()
+		if err != nil {
+			return err
+		}
+		n, err := admin.Remove(ctx, fs.Args())
+		if err != nil {
+			return err
+		}
+		c.out.Note("removed %d of %d links", n, fs.NArg())
+		return nil
+	}
+
+	items := make([]model.URLDeletion, 0, fs.NArg())
+	for _, id := range fs.Args() {
+		url, err := c.repo.FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if url == nil || url.Deleted {
+			continue
+		}
+		items = append(items, model.URLDeletion{UserID: url.UserID, ID: url.ID})
+	}
+	if err := c.repo.MarkDeleted(ctx, items); err != nil {
+		return err
+	}
+	c.out.Note("deleted %d of %d links", len(items), fs.NArg())
+	return nil
+}
+
+func runCount(ctx context.Context, c *cli, args []string) error {
+	urls, err := c.repo.CountURLs(ctx)
+	if err != nil {
+		return err
+	}
+	users, err := c.repo.CountUsers(ctx)
+	if err != nil {
+		return err
+	}
+	return c.out.Counts(counts{URLs: urls, Users: users})
+}
+
+func runVerify(ctx context.Context, c *cli, args []string) error {
+	admin, err := c.admin()
+	if err != nil {
+		return err
+	}
+	problems, err := admin.Verify(ctx)
+	if err != nil {
+		return err
+	}
+	if err := c.out.Problems(problems); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return errProblemsFound
+	}
+	return nil
+}
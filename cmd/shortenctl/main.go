@@ -0,0 +1,134 @@
+// shortenctl — утилита обслуживания хранилища ссылок. Работает с хранилищем напрямую,
+// поэтому файловое хранилище перед изменениями нужно освободить, остановив сервер.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"url-shortener/internal/config"
+	"url-shortener/internal/repository"
+)
+
+const usage = `Usage: shortenctl [storage flags] [-o table|json] <command> [arguments]
+
+Commands:
+  list [-deleted] [-user ID] [-after CURSOR] [-limit N]   list links in creation order
+  get ID...                                               look up links by ID
+  find URL                                                look up a link by original URL
+  create [-alias A] [-user ID] [-ttl D] [-expires-at T] URL
+                                                          shorten a URL
+  delete [-purge] ID...                                   mark links deleted or remove them permanently
+  count                                                   count links and users
+  verify                                                  check storage integrity
+  export [-format jsonl|csv] [-out FILE] [-after CURSOR]  stream all links, including deleted ones
+  import [-format jsonl|csv] [-in FILE] [-dry-run] [-base-url URL]
+                                                          load an export, skipping links already present
+
+Storage is selected the same way as for the server: -d / DATABASE_DSN, -f / FILE_STORAGE_PATH
+or a config file from -c / CONFIG. The file storage is locked while the server runs:
+stop the server first. Read-only commands may run side by side.
+
+To move links between storages, pipe an export into an import:
+  shortenctl -f ./tmp/shorten_url.json export | shortenctl -d postgres://... import
+`
+
+// errProblemsFound — проверка прошла, но нашла нарушения; сообщение уже напечатано.
+var errProblemsFound = errors.New("integrity problems found")
+
+// cli — окружение команды: открытое хранилище, конфигурация, потоки ввода-вывода и формат вывода.
+type cli struct {
+	cfg    *config.Config
+	repo   repository.URLRepository
+	stdin  io.Reader
+	stdout io.Writer
+	out    printer
+}
+
+type command struct {
+	run func(ctx context.Context, c *cli, args []string) error
+	// readOnly — команда не меняет хранилище и может работать рядом с другими читателями
+	readOnly bool
+}
+
+var commands = map[string]command{
+	"list":   {run: runList, readOnly: true},
+	"get":    {run: runGet, readOnly: true},
+	"find":   {run: runFind, readOnly: true},
+	"create": {run: runCreate},
+	"delete": {run: runDelete},
+	"count":  {run: runCount, readOnly: true},
+	"verify": {run: runVerify, readOnly: true},
+	"export": {run: runExport, readOnly: true},
+	"import": {run: runImport},
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := run(ctx, os.Args[1:], os.Stdin, os.Stdout)
+	stop()
+
+	if err != nil {
+		if !errors.Is(err, errProblemsFound) && !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "shortenctl:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) (err error) {
+	fs := flag.NewFlagSet("shortenctl", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+	}
+	var format string
+	fs.StringVar(&format, "o", formatTable, "Output format: table or json")
+
+	cfg, err := config.Load(fs, args, os.Getenv)
+	if err != nil {
+		return err
+	}
+	out, err := newPrinter(format, stdout)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		return fmt.Errorf("unknown command %q", fs.Arg(0))
+	}
+
+	repo, err := cfg.OpenURLRepository(cmd.readOnly)
+	if errors.Is(err, repository.ErrLocked) {
+		return fmt.Errorf("storage error: %w; stop the server before running shortenctl", err)
+	}
+	if err != nil {
+		return fmt.Errorf("storage error: %w", err)
+	}
+	defer func() {
+		if closer, ok := repo.(io.Closer); ok {
+			if closeErr := closer.Close(); closeErr != nil && err == nil {
+				err = fmt.Errorf("failed to close storage: %w", closeErr)
+			}
+		}
+	}()
+
+	return cmd.run(ctx, &cli{cfg: cfg, repo: repo, stdin: stdin, stdout: stdout, out: out}, fs.Args()[1:])
+}
+
+// admin возвращает операции обслуживания хранилища, если оно их поддерживает.
+func (c *cli) admin() (repository.Admin, error) {
+	admin, ok := c.repo.(repository.Admin)
+	if !ok {
+		return nil, fmt.Errorf("storage does not support maintenance operations")
+	}
+	return admin, nil
+}
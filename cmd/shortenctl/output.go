@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+	"url-shortener/internal/model"
+	"url-shortener/internal/repository"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+type counts struct {
+	URLs  int `json:"urls"`
+	Users int `json:"users"`
+}
+
+// printer выводит результаты команд. Служебные сообщения идут в stderr, чтобы не портить JSON в stdout.
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+func newPrinter(format string, w io.Writer) (printer, error) {
+	switch format {
+	case formatTable:
+		return printer{w: w}, nil
+	case formatJSON:
+		return printer{w: w, json: true}, nil
+	default:
+		return printer{}, fmt.Errorf("unknown output format %q (expected table or json)", format)
+	}
+}
+
+func (p printer) URLs(urls []*model.URL) error {
+	if p.json {
+		if urls == nil {
+			urls = []*model.URL{}
+		}
+		return p.encode(urls)
+	}
+
+	w := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tORIGINAL\tUSER\tCREATED AT\tEXPIRES AT\tDELETED")
+	for _, url := range urls {
+		expiresAt := "-"
+		if url.ExpiresAt != nil {
+			expiresAt = url.ExpiresAt.Format(time.RFC3339)
+		}
+		userID := url.UserID
+		if userID == "" {
+			userID = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%t\n",
+			url.ID, url.Original, userID, url.CreatedAt.Format(time.RFC3339), expiresAt, url.Deleted)
+	}
+	return w.Flush()
+}
+
+func (p printer) Counts(c counts) error {
+	if p.json {
+		return p.encode(c)
+	}
+	w := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "URLS\t%d\n", c.URLs)
+	fmt.Fprintf(w, "USERS\t%d\n", c.Users)
+	return w.Flush()
+}
+
+func (p printer) Problems(problems []repository.Problem) error {
+	if p.json {
+		if problems == nil {
+			problems = []repository.Problem{}
+		}
+		return p.encode(problems)
+	}
+	if len(problems) == 0 {
+		p.Note("no problems found")
+		return nil
+	}
+	w := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tID\tDETAIL")
+	for _, problem := range problems {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", problem.Kind, problem.ID, problem.Detail)
+	}
+	return w.Flush()
+}
+
+func (p printer) Note(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+}
+
+func (p printer) encode(v any) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
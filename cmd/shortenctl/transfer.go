@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"url-shortener/internal/transfer"
+)
+
+// transferFormat возвращает формат из флага, а если он не задан — по расширению файла.
+func transferFormat(format, path string) string {
+	if format != "" {
+		return format
+	}
+	if filepath.Ext(path) == ".csv" {
+		return transfer.FormatCSV
+	}
+	return transfer.FormatJSONL
+}
+
+// runExport выгружает ссылки потоком. Прерванную выгрузку продолжают с курсором из сообщения:
+// с -after файл из -out дописывается, а не перезаписывается.
+func runExport(ctx context.Context, c *cli, args []string) (err error) {
+	fs := newFlagSet("export", "[flags]")
+	format := fs.String("format", "", "Export format: jsonl or csv (default by -out extension, jsonl)")
+	outPath := fs.String("out", "", "Output file (default stdout)")
+	after := fs.String("after", "", "Continue after this cursor")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	admin, err := c.admin()
+	if err != nil {
+		return err
+	}
+
+	out := c.stdout
+	header := *after == ""
+	if *outPath != "" {
+		flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if *after != "" {
+			flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		}
+		f, err := os.OpenFile(*outPath, flags, 0644)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if closeErr := f.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}()
+		out = f
+	}
+	w, err := transfer.NewWriter(transferFormat(*format, *outPath), out, header)
+	if err != nil {
+		return err
+	}
+
+	result, err := transfer.Export(ctx, admin, *after, w)
+	if err != nil {
+		if result.Cursor != "" {
+			c.out.Note("exported %d links before failure, continue with -after %s", result.Count, result.Cursor)
+		}
+		return err
+	}
+	c.out.Note("exported %d links", result.Count)
+	return nil
+}
+
+// runImport записывает выгрузку в хранилище. Уже перенесенные ссылки пропускаются, поэтому
+// прерванный импорт продолжается повторным запуском той же команды.
+func runImport(ctx context.Context, c *cli, args []string) error {
+	fs := newFlagSet("import", "[flags]")
+	format := fs.String("format", "", "Export format: jsonl or csv (default by -in extension, jsonl)")
+	inPath := fs.String("in", "", "Input file (default stdin)")
+	var opts transfer.ImportOptions
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "Only report conflicts, write nothing")
+	fs.StringVar(&opts.BaseURL, "base-url", "", "Rewrite short links to this base URL")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	in := c.stdin
+	if *inPath != "" {
+		f, err := os.Open(*inPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	r, err := transfer.NewReader(transferFormat(*format, *inPath), in)
+	if err != nil {
+		return err
+	}
+
+	result, err := transfer.Import(ctx, c.repo, r, opts)
+	if printErr := c.out.Problems(result.Conflicts); printErr != nil {
+		return errors.Join(err, printErr)
+	}
+	action := "imported"
+	if opts.DryRun {
+		action = "would import"
+	}
+	c.out.Note("read %d links: %s %d, already present %d, conflicts %d",
+		result.Read, action, result.Imported, result.Existing, len(result.Conflicts))
+	if err != nil {
+		if !opts.DryRun && !errors.Is(err, io.ErrUnexpectedEOF) {
+			c.out.Note("import stopped, run the same command again to continue")
+		}
+		return fmt.Errorf("import failed after %d links: %w", result.Read, err)
+	}
+	if len(result.Conflicts) > 0 {
+		return errProblemsFound
+	}
+	return nil
+}
@@ -1,50 +1,292 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"log"
+	"net"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
 	"url-shortener/internal/config"
+	"url-shortener/internal/grpcserver"
 	"url-shortener/internal/handler"
+	"url-shortener/internal/metrics"
 	"url-shortener/internal/middleware"
+	"url-shortener/internal/ratelimit"
 	"url-shortener/internal/service"
+	"url-shortener/internal/tlsutil"
+	"url-shortener/internal/tracing"
 )
 
+// redirectReadHeaderTimeout защищает HTTP-сервер перенаправлений от медленных клиентов.
+const redirectReadHeaderTimeout = 5 * time.Second
+
 func loadConfig() *config.Config {
-	cfg := config.Init()
+	cfg, err := config.Init()
+	if err != nil {
+		log.Fatalf("Configuration error: %v", err)
+	}
 
 	// Проверяем корректность конфигурации
 	if err := cfg.Validate(); err != nil {
 		log.Fatalf("Configuration error: %v", err)
 	}
-	log.Printf("Configuration loaded: %v", cfg)
+	for _, warning := range cfg.Warnings() {
+		log.Printf("Configuration warning: %s", warning)
+	}
+	// Не логируем конфигурацию целиком: в ней есть DSN и ключ подписи
+	log.Printf("Configuration loaded: server=%s https=%t base_url=%s file_storage=%s database=%t",
+		cfg.ServerAddress, cfg.EnableHTTPS, cfg.BaseURL, cfg.FileStoragePath, cfg.DatabaseDSN != "")
 
 	return cfg
 }
 
 func main() {
 	cfg := loadConfig()
-	defer cfg.Close()
 
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(cfg, flag.Args()[1:]); err != nil {
+			log.Fatalf("Migration error: %v", err)
+		}
+		return
+	}
+
+	if err := run(cfg); err != nil {
+		log.Fatalf("Server error: %v", err)
+	}
+}
+
+func run(cfg *config.Config) error {
 	logger := middleware.InitLogger()
 	defer logger.Sync()
+	// Фоновые задачи и хранилище пишут в глобальный логгер, запросы — в логгер из контекста
+	undoGlobals := zap.ReplaceGlobals(logger.Desugar())
+	defer undoGlobals()
 
-	// repo := repository.NewInMemoryURLRepository()
-	urlService := service.NewURLService(cfg.URLRepository, cfg.BaseURL)
+	if err := cfg.InitRepository(); err != nil {
+		return fmt.Errorf("storage error: %w", err)
+	}
+	// Хранилище закрывается последним, после остановки сервера и фоновых задач
+	defer func() {
+		if err := cfg.Close(); err != nil {
+			log.Printf("Failed to close storage: %v", err)
+		}
+	}()
+
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.TracingOptions())
+	if err != nil {
+		return fmt.Errorf("tracing error: %w", err)
+	}
+	// Трассировка останавливается после сервиса, чтобы успеть отправить спаны фоновых задач
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Printf("Failed to flush traces: %v", err)
+		}
+	}()
+
+	appMetrics := metrics.New()
+	measuredRepo := metrics.InstrumentRepository(cfg.URLRepository, appMetrics)
+	appMetrics.RegisterStoredURLs(measuredRepo.CountURLs)
+	repo := tracing.InstrumentRepository(measuredRepo)
+
+	secret := cfg.AuthSecret()
+	urlService := service.NewURLService(repo, cfg.BaseURL,
+		service.WithMetrics(appMetrics),
+		service.WithReapInterval(cfg.ReapInterval),
+		service.WithTimeouts(service.Timeouts{
+			Lookup: cfg.LookupTimeout,
+			Store:  cfg.StoreTimeout,
+			Stats:  cfg.StatsTimeout,
+		}),
+		service.WithClicks(cfg.ClickRepository, service.ClickOptions{
+			BufferSize: cfg.ClickBufferSize,
+			IPKey:      cfg.ClickIPKey(),
+		}))
 	handlers := handler.NewHandler(urlService)
 
+	// Подсеть уже проверена в Validate
+	trustedSubnet, err := middleware.ParseTrustedSubnet(cfg.TrustedSubnet)
+	if err != nil {
+		return err
+	}
+
+	// Лимиты уже проверены в Validate
+	shortenLimit, err := ratelimit.ParseLimit(cfg.ShortenRateLimit, cfg.ShortenRateBurst)
+	if err != nil {
+		return err
+	}
+	redirectLimit, err := ratelimit.ParseLimit(cfg.RedirectRateLimit, cfg.RedirectRateBurst)
+	if err != nil {
+		return err
+	}
+	limits := ratelimit.NewMemoryStore()
+	limitShorten := ratelimit.Middleware(limits, "shorten", shortenLimit)
+	limitRedirect := ratelimit.Middleware(limits, "redirect", redirectLimit)
+
 	// Настройка маршрутов
-	router := gin.Default()
+	// gin.Default добавил бы собственный журнал запросов поверх HTTPLoggerMiddleware
+	router := gin.New()
+	router.Use(gin.Recovery())
+	// Без списка доверенных прокси адрес клиента берется из соединения, а не из заголовков
+	if err := router.SetTrustedProxies(cfg.TrustedProxyList()); err != nil {
+		return fmt.Errorf("invalid trusted proxies: %w", err)
+	}
 
+	router.Use(middleware.RequestIDMiddleware())
+	router.Use(tracing.Middleware())
 	router.Use(middleware.GzipMiddleware())
 	router.Use(middleware.HTTPLoggerMiddleware(logger))
+	router.Use(appMetrics.Middleware())
+	// Метрики регистрируются до аутентификации, чтобы сборщику не выдавалась cookie
+	router.GET("/metrics", middleware.TrustedSubnet(trustedSubnet), gin.WrapH(appMetrics.Handler()))
+	auth := middleware.NewAuthenticator(secret)
+	router.Use(middleware.AuthMiddleware(auth))
 
 	// Регистрируем обработчики
-	router.POST("/", handlers.ShortenURL)
-	router.GET("/:id", handlers.GetOriginalURL)
+	router.POST("/", limitShorten, handlers.ShortenURL)
+	router.GET("/ping", handlers.Ping)
+	router.GET("/:id", limitRedirect, handlers.GetOriginalURL)
 	// Регистрируем обработчики JSON
-	router.POST("/api/shorten", handlers.ShortenJSONUrl)
+	router.POST("/api/shorten", limitShorten, handlers.ShortenJSONUrl)
+	router.POST("/api/shorten/batch", limitShorten, handlers.ShortenBatch)
+	router.GET("/api/user/urls", middleware.RequireAuth(), handlers.GetUserURLs)
+	router.DELETE("/api/user/urls", middleware.RequireAuth(), handlers.DeleteUserURLs)
+	router.GET("/api/urls/:id/stats", middleware.RequireAuth(), handlers.GetURLStats)
+	router.GET("/api/internal/stats", middleware.TrustedSubnet(trustedSubnet), handlers.GetServiceStats)
+
+	server := &http.Server{
+		Addr:    cfg.ServerAddress,
+		Handler: router,
+	}
+	servers := []*http.Server{server}
+	serve := server.ListenAndServe
+	var grpcOpts []grpc.ServerOption
+
+	if cfg.EnableHTTPS {
+		if cfg.TLSCertFile == "" {
+			log.Printf("TLS certificate is not configured, using a self-signed one; do not use it in production")
+		}
+		tlsConfig, err := tlsutil.ServerConfig(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSHosts())
+		if err != nil {
+			return fmt.Errorf("TLS error: %w", err)
+		}
+		server.TLSConfig = tlsConfig
+		grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+		// Сертификат и ключ уже в TLSConfig
+		serve = func() error { return server.ListenAndServeTLS("", "") }
 
-	// Запуск сервера
-	//log.Printf("Server starting on %s %s", cfg.BaseURL, cfg.ServerAddress)
-	router.Run(cfg.ServerAddress)
+		if cfg.HTTPRedirectAddress != "" {
+			// Адрес уже проверен в Validate
+			_, httpsPort, _ := net.SplitHostPort(cfg.ServerAddress)
+			servers = append(servers, &http.Server{
+				Addr:              cfg.HTTPRedirectAddress,
+				Handler:           tlsutil.RedirectHandler(httpsPort),
+				ReadHeaderTimeout: redirectReadHeaderTimeout,
+			})
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	defer stop()
+
+	// gRPC работает на отдельном адресе с тем же сервисом и той же подписью токенов
+	var grpcServer *grpc.Server
+	var grpcListener net.Listener
+	if cfg.GRPCAddress != "" {
+		grpcListener, err = net.Listen("tcp", cfg.GRPCAddress)
+		if err != nil {
+			// Фоновые задачи сервиса уже запущены и должны успеть сбросить накопленное
+			return errors.Join(fmt.Errorf("failed to listen gRPC address: %w", err),
+				shutdown(servers, nil, urlService, cfg.ShutdownTimeout))
+		}
+		grpcServer = grpcserver.New(urlService, auth, logger, grpcserver.RateLimits{
+			Store:   limits,
+			Shorten: shortenLimit,
+			Resolve: redirectLimit,
+		}, grpcOpts...)
+	}
+
+	// Запуск серверов
+	serverErr := make(chan error, len(servers)+1)
+	listen := func(addr string, serve func() error) {
+		go func() {
+			if err := serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverErr <- fmt.Errorf("%s: %w", addr, err)
+			}
+		}()
+	}
+	log.Printf("Server starting on %s", cfg.ServerAddress)
+	listen(server.Addr, serve)
+	for _, redirect := range servers[1:] {
+		log.Printf("Redirecting HTTP requests from %s to HTTPS", redirect.Addr)
+		listen(redirect.Addr, redirect.ListenAndServe)
+	}
+	if grpcServer != nil {
+		log.Printf("gRPC server starting on %s", cfg.GRPCAddress)
+		listen(cfg.GRPCAddress, func() error { return grpcServer.Serve(grpcListener) })
+	}
+
+	select {
+	case err := <-serverErr:
+		// Остальные серверы и фоновые задачи останавливаются так же, как по сигналу
+		return errors.Join(fmt.Errorf("failed to start server: %w", err),
+			shutdown(servers, grpcServer, urlService, cfg.ShutdownTimeout))
+	case <-ctx.Done():
+		log.Printf("Shutdown signal received, draining requests")
+	}
+
+	return shutdown(servers, grpcServer, urlService, cfg.ShutdownTimeout)
+}
+
+// shutdown перестает принимать соединения, дожидается текущих запросов и фоновых задач.
+// Все этапы укладываются в общий таймаут.
+func shutdown(servers []*http.Server, grpcServer *grpc.Server, urlService service.URLService, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to drain requests on %s: %w", server.Addr, err))
+		}
+	}
+	if grpcServer != nil {
+		if err := stopGRPC(ctx, grpcServer); err != nil {
+			errs = append(errs, fmt.Errorf("failed to drain gRPC calls: %w", err))
+		}
+	}
+	if err := urlService.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to stop background workers: %w", err))
+	}
+	if len(errs) == 0 {
+		log.Printf("Server stopped gracefully")
+	}
+	return errors.Join(errs...)
+}
+
+// stopGRPC дожидается завершения текущих вызовов, а по истечении ctx обрывает их.
+func stopGRPC(ctx context.Context, server *grpc.Server) error {
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		server.Stop()
+		return ctx.Err()
+	}
 }
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+	"url-shortener/internal/config"
+	"url-shortener/internal/config/db"
+	"url-shortener/migrations"
+)
+
+const migrateCommandTimeout = 5 * time.Minute
+
+// runMigrate обрабатывает подкоманду `shortener migrate up|down [N]|status`.
+func runMigrate(cfg *config.Config, args []string) error {
+	if cfg.DatabaseDSN == "" {
+		return fmt.Errorf("database DSN is not configured (use -d or DATABASE_DSN)")
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("usage: shortener migrate up|down [N]|status")
+	}
+
+	conn, err := db.Open(cfg.DatabaseDSN)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	migrator, err := db.NewMigrator(conn, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), migrateCommandTimeout)
+	defer cancel()
+
+	switch args[0] {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		return migrator.Down(ctx, steps)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		printMigrationStatus(statuses)
+		return err
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
+
+func printMigrationStatus(statuses []db.MigrationStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range statuses {
+		status, appliedAt := "pending", "-"
+		if s.Applied {
+			status, appliedAt = "applied", s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, s.Name, status, appliedAt)
+	}
+	w.Flush()
+}
@@ -1,68 +1,547 @@
 package config
 
 import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"log"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"url-shortener/internal/config/db"
+	"url-shortener/internal/middleware"
+	"url-shortener/internal/ratelimit"
 	"url-shortener/internal/repository"
+	"url-shortener/internal/tracing"
 )
 
 type Config struct {
-	ServerAddress   string
+	ServerAddress string
+	// EnableHTTPS включает TLS; без TLSCertFile и TLSKeyFile выпускается самоподписанный сертификат
+	EnableHTTPS bool
+	TLSCertFile string
+	TLSKeyFile  string
+	// HTTPRedirectAddress — адрес HTTP-сервера, перенаправляющего на HTTPS; пустой — не запускать
+	HTTPRedirectAddress string
+	// GRPCAddress — адрес gRPC-сервера; пустой — не запускать
+	GRPCAddress     string
 	BaseURL         string
 	FileStoragePath string
-	URLRepository   repository.URLRepository
+	// FileSyncInterval — период fsync журнала файлового хранилища, 0 — после каждой записи
+	FileSyncInterval time.Duration
+	// FileCompactInterval — период сжатия журналов ссылок и переходов в снимки
+	FileCompactInterval time.Duration
+	DatabaseDSN         string
+	// SecretKey — ключ подписи cookie с идентификатором пользователя
+	SecretKey string
+	// ShutdownTimeout — сколько ждать завершения запросов и фоновых задач при остановке
+	ShutdownTimeout time.Duration
+	// LookupTimeout, StoreTimeout и StatsTimeout ограничивают операции с хранилищем в запросах:
+	// поиск ссылок, сохранение новых и статистику; 0 — без ограничения
+	LookupTimeout time.Duration
+	StoreTimeout  time.Duration
+	StatsTimeout  time.Duration
+	// ReapInterval — период удаления ссылок с истекшим сроком действия, 0 — не удалять
+	ReapInterval time.Duration
+	// ClickBufferSize — емкость буфера событий переходов
+	ClickBufferSize int
+	// TrustedSubnet — подсеть в нотации CIDR, которой доступны внутренняя статистика и метрики
+	TrustedSubnet string
+	// TrustedProxies — адреса и подсети прокси через запятую, которым можно верить в X-Forwarded-For и X-Real-IP
+	TrustedProxies string
+	// Ограничение частоты запросов к сокращению и редиректам: скорость вида 60/m и размер всплеска; скорость 0 отключает лимит
+	ShortenRateLimit  string
+	ShortenRateBurst  int
+	RedirectRateLimit string
+	RedirectRateBurst int
+	// Трассировка: экспортер (none, stdout, otlp), файл для stdout, адрес OTLP-коллектора и доля записываемых трассировок
+	TraceExporter    string
+	TraceFile        string
+	TraceEndpoint    string
+	TraceSampleRatio float64
+	URLRepository    repository.URLRepository
+	ClickRepository  repository.ClickRepository
+
+	// secretGenerated — SecretKey не задан и сгенерирован при запуске
+	secretGenerated bool
+}
+
+// source связывает флаг с переменной окружения. Ключ в файле конфигурации — имя переменной
+// в нижнем регистре, например server_address.
+type source struct {
+	flag string
+	env  string
+}
+
+var sources = []source{
+	{"a", "SERVER_ADDRESS"},
+	{"s", "ENABLE_HTTPS"},
+	{"tls-cert", "TLS_CERT_FILE"},
+	{"tls-key", "TLS_KEY_FILE"},
+	{"http-redirect-address", "HTTP_REDIRECT_ADDRESS"},
+	{"grpc-address", "GRPC_ADDRESS"},
+	{"b", "BASE_URL"},
+	{"f", "FILE_STORAGE_PATH"},
+	{"file-sync-interval", "FILE_SYNC_INTERVAL"},
+	{"file-compact-interval", "FILE_COMPACT_INTERVAL"},
+	{"d", "DATABASE_DSN"},
+	{"k", "SECRET_KEY"},
+	{"shutdown-timeout", "SHUTDOWN_TIMEOUT"},
+	{"lookup-timeout", "LOOKUP_TIMEOUT"},
+	{"store-timeout", "STORE_TIMEOUT"},
+	{"stats-timeout", "STATS_TIMEOUT"},
+	{"reap-interval", "REAP_INTERVAL"},
+	{"click-buffer-size", "CLICK_BUFFER_SIZE"},
+	{"t", "TRUSTED_SUBNET"},
+	{"trusted-proxies", "TRUSTED_PROXIES"},
+	// Пустое значение переменной не отличить от незаданной, поэтому лимит отключается значением 0
+	{"shorten-rate-limit", "SHORTEN_RATE_LIMIT"},
+	{"shorten-rate-burst", "SHORTEN_RATE_BURST"},
+	{"redirect-rate-limit", "REDIRECT_RATE_LIMIT"},
+	{"redirect-rate-burst", "REDIRECT_RATE_BURST"},
+	{"trace-exporter", "TRACE_EXPORTER"},
+	{"trace-file", "TRACE_FILE"},
+	{"trace-endpoint", "TRACE_ENDPOINT"},
+	{"trace-sample-ratio", "TRACE_SAMPLE_RATIO"},
+}
+
+// Init читает конфигурацию из аргументов командной строки, окружения и файла конфигурации.
+func Init() (*Config, error) {
+	return Load(flag.CommandLine, os.Args[1:], os.Getenv)
 }
 
-func Init() *Config {
+// Load собирает конфигурацию. Источники по убыванию приоритета: флаги, переменные окружения,
+// файл из -c или CONFIG (JSON или YAML), значения по умолчанию. Все значения разбираются
+// так же, как флаги, а ошибки всех источников возвращаются вместе.
+func Load(fs *flag.FlagSet, args []string, getenv func(string) string) (*Config, error) {
 	cfg := &Config{}
+	cfg.registerFlags(fs)
+	var configPath string
+	fs.StringVar(&configPath, "c", "", "Config file in JSON or YAML format")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
 
-	flag.StringVar(&cfg.ServerAddress, "a", "localhost:8080", "HTTP server address")
-	flag.StringVar(&cfg.BaseURL, "b", "http://localhost:8080", "Base URL for short links")
-	flag.StringVar(&cfg.FileStoragePath, "f", "./tmp/shorten_url.json", "File storage path")
-	flag.Parse()
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	if !explicit["c"] {
+		configPath = getenv("CONFIG")
+	}
 
-	if envServer := os.Getenv("SERVER_ADDRESS"); envServer != "" {
-		cfg.ServerAddress = envServer
+	var errs []error
+	if configPath != "" {
+		values, err := readConfigFile(configPath)
+		if err != nil {
+			return nil, err
+		}
+		flagByKey := make(map[string]string, len(sources))
+		for _, src := range sources {
+			flagByKey[strings.ToLower(src.env)] = src.flag
+		}
+
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			name, ok := flagByKey[key]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s: unknown option %q", configPath, key))
+				continue
+			}
+			if explicit[name] {
+				continue
+			}
+			if err := fs.Set(name, values[key]); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s: %w", configPath, key, err))
+			}
+		}
 	}
 
-	if envBaseURL := os.Getenv("BASE_URL"); envBaseURL != "" {
-		cfg.BaseURL = envBaseURL
+	for _, src := range sources {
+		value := getenv(src.env)
+		if value == "" || explicit[src.flag] {
+			continue
+		}
+		if err := fs.Set(src.flag, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", src.env, err))
+		}
 	}
 
-	if envFileStorage := os.Getenv("FILE_STORAGE_PATH"); envFileStorage != "" {
-		cfg.FileStoragePath = envFileStorage
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.ServerAddress, "a", "localhost:8080", "HTTP server address")
+	fs.BoolVar(&c.EnableHTTPS, "s", false, "Serve HTTPS")
+	fs.StringVar(&c.TLSCertFile, "tls-cert", "", "TLS certificate file (self-signed if empty)")
+	fs.StringVar(&c.TLSKeyFile, "tls-key", "", "TLS private key file")
+	fs.StringVar(&c.HTTPRedirectAddress, "http-redirect-address", "", "Plain HTTP address redirecting to HTTPS (empty to disable)")
+	fs.StringVar(&c.GRPCAddress, "grpc-address", "", "gRPC server address, e.g. localhost:3200 (empty to disable)")
+	fs.StringVar(&c.BaseURL, "b", "http://localhost:8080", "Base URL for short links")
+	fs.StringVar(&c.FileStoragePath, "f", "./tmp/shorten_url.json", "File storage path")
+	fs.DurationVar(&c.FileSyncInterval, "file-sync-interval", 0, "File storage fsync interval (0 to sync every write)")
+	fs.DurationVar(&c.FileCompactInterval, "file-compact-interval", 5*time.Minute, "File storage and click log compaction interval (0 to disable)")
+	fs.StringVar(&c.DatabaseDSN, "d", "", "PostgreSQL DSN")
+	fs.StringVar(&c.SecretKey, "k", "", "Secret key for signing auth cookies")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", 10*time.Second, "Graceful shutdown timeout")
+	fs.DurationVar(&c.LookupTimeout, "lookup-timeout", 2*time.Second, "Storage lookup timeout per request (0 to disable)")
+	fs.DurationVar(&c.StoreTimeout, "store-timeout", 5*time.Second, "Storage write timeout per request (0 to disable)")
+	fs.DurationVar(&c.StatsTimeout, "stats-timeout", 10*time.Second, "Statistics query timeout per request (0 to disable)")
+	fs.DurationVar(&c.ReapInterval, "reap-interval", time.Minute, "Expired links cleanup interval (0 to disable)")
+	fs.IntVar(&c.ClickBufferSize, "click-buffer-size", 10000, "Click events buffer size")
+	fs.StringVar(&c.TrustedSubnet, "t", "", "Trusted subnet (CIDR) for internal endpoints")
+	fs.StringVar(&c.TrustedProxies, "trusted-proxies", "", "Comma-separated trusted proxy addresses or CIDRs")
+	fs.StringVar(&c.ShortenRateLimit, "shorten-rate-limit", "60/m", "Shorten rate limit per client, e.g. 60/m (0 to disable)")
+	fs.IntVar(&c.ShortenRateBurst, "shorten-rate-burst", 30, "Shorten requests allowed in a burst")
+	fs.StringVar(&c.RedirectRateLimit, "redirect-rate-limit", "100/s", "Redirect rate limit per client, e.g. 100/s (0 to disable)")
+	fs.IntVar(&c.RedirectRateBurst, "redirect-rate-burst", 200, "Redirects allowed in a burst")
+	fs.StringVar(&c.TraceExporter, "trace-exporter", tracing.ExporterNone, "Trace exporter: none, stdout or otlp")
+	fs.StringVar(&c.TraceFile, "trace-file", "", "File for the stdout trace exporter (default stdout)")
+	fs.StringVar(&c.TraceEndpoint, "trace-endpoint", "", "OTLP/HTTP collector endpoint, e.g. http://localhost:4318")
+	fs.Float64Var(&c.TraceSampleRatio, "trace-sample-ratio", 1, "Fraction of traces to record")
+}
+
+// readConfigFile читает плоский объект из JSON- или YAML-файла; формат определяется по расширению.
+// Значения возвращаются строками и разбираются так же, как флаги.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	values := make(map[string]string)
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		var raw map[string]json.RawMessage
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+		for key, value := range raw {
+			value = bytes.TrimSpace(value)
+			switch {
+			case len(value) > 0 && value[0] == '"':
+				var str string
+				if err := json.Unmarshal(value, &str); err != nil {
+					return nil, fmt.Errorf("failed to parse config file %s: %s: %w", path, key, err)
+				}
+				values[key] = str
+			case len(value) > 0 && (value[0] == '{' || value[0] == '[' || string(value) == "null"):
+				return nil, fmt.Errorf("failed to parse config file %s: %s must be a string, number or boolean", path, key)
+			default:
+				values[key] = string(value)
+			}
+		}
+	case ".yaml", ".yml":
+		// Скалярные значения YAML любого типа декодируются в строку как есть
+		if err := yaml.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config file format %q: use .json, .yaml or .yml", ext)
 	}
-	cfg.initRepository()
-	return cfg
+	return values, nil
 }
 
+// Validate проверяет конфигурацию целиком и возвращает все найденные ошибки разом.
 func (c *Config) Validate() error {
+	var errs []error
 	if c.ServerAddress == "" {
-		return fmt.Errorf("server address cannot be empty")
+		errs = append(errs, fmt.Errorf("server address cannot be empty"))
+	} else if _, _, err := net.SplitHostPort(c.ServerAddress); err != nil {
+		errs = append(errs, fmt.Errorf("invalid server address %q: %w", c.ServerAddress, err))
+	}
+	if c.GRPCAddress != "" {
+		if _, _, err := net.SplitHostPort(c.GRPCAddress); err != nil {
+			errs = append(errs, fmt.Errorf("invalid gRPC address %q: %w", c.GRPCAddress, err))
+		}
+	}
+	if err := validateBaseURL(c.BaseURL); err != nil {
+		errs = append(errs, err)
+	}
+	errs = append(errs, c.validateTLS()...)
+	// Файловое хранилище используется, только если не задана база данных
+	if c.DatabaseDSN == "" && c.FileStoragePath != "" {
+		if err := validateStorageDir(filepath.Dir(c.FileStoragePath)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("shutdown timeout must be positive"))
+	}
+	if c.LookupTimeout < 0 || c.StoreTimeout < 0 || c.StatsTimeout < 0 {
+		errs = append(errs, fmt.Errorf("storage timeouts cannot be negative"))
+	}
+	if c.ReapInterval < 0 {
+		errs = append(errs, fmt.Errorf("reap interval cannot be negative"))
+	}
+	if c.ClickBufferSize <= 0 {
+		errs = append(errs, fmt.Errorf("click buffer size must be positive"))
+	}
+	if _, err := middleware.ParseTrustedSubnet(c.TrustedSubnet); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := ratelimit.ParseLimit(c.ShortenRateLimit, c.ShortenRateBurst); err != nil {
+		errs = append(errs, fmt.Errorf("shorten: %w", err))
+	}
+	if _, err := ratelimit.ParseLimit(c.RedirectRateLimit, c.RedirectRateBurst); err != nil {
+		errs = append(errs, fmt.Errorf("redirect: %w", err))
+	}
+	if err := c.TracingOptions().Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Config) validateTLS() []error {
+	var errs []error
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, fmt.Errorf("TLS certificate and key must be set together"))
+	} else if c.EnableHTTPS && c.TLSCertFile != "" {
+		if _, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile); err != nil {
+			errs = append(errs, fmt.Errorf("invalid TLS certificate: %w", err))
+		}
+	}
+	if c.HTTPRedirectAddress != "" {
+		if !c.EnableHTTPS {
+			errs = append(errs, fmt.Errorf("HTTP redirect address requires HTTPS to be enabled"))
+		} else if _, _, err := net.SplitHostPort(c.HTTPRedirectAddress); err != nil {
+			errs = append(errs, fmt.Errorf("invalid HTTP redirect address %q: %w", c.HTTPRedirectAddress, err))
+		}
+	}
+	return errs
+}
+
+// Warnings возвращает сомнительные, но допустимые настройки.
+func (c *Config) Warnings() []string {
+	var warnings []string
+	if u, err := url.Parse(c.BaseURL); err == nil {
+		if c.EnableHTTPS && u.Scheme == "http" {
+			warnings = append(warnings, "HTTPS is enabled but base URL uses http, short links will point to plain HTTP")
+		}
+		// За прокси, завершающим TLS, это нормально, поэтому только предупреждение
+		if !c.EnableHTTPS && u.Scheme == "https" {
+			warnings = append(warnings, "base URL uses https but HTTPS is disabled, expecting a TLS-terminating proxy")
+		}
+	}
+	return warnings
+}
+
+// TLSHosts возвращает имена для самоподписанного сертификата: хосты из BaseURL и адреса сервера и localhost.
+func (c *Config) TLSHosts() []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if u, err := url.Parse(c.BaseURL); err == nil && u.Hostname() != "" {
+		hosts = append(hosts, u.Hostname())
 	}
-	if c.BaseURL == "" {
+	if host, _, err := net.SplitHostPort(c.ServerAddress); err == nil && host != "" {
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+func validateBaseURL(baseURL string) error {
+	if baseURL == "" {
 		return fmt.Errorf("base URL cannot be empty")
 	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return fmt.Errorf("invalid base URL: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid base URL %q: expected absolute http or https URL", baseURL)
+	}
 	return nil
 }
 
-func (c *Config) initRepository() {
-	if c.FileStoragePath != "" {
-		fileRepo, err := repository.NewFileURLRepository(c.FileStoragePath)
+// validateStorageDir проверяет, что каталог хранилища существует или может быть создан:
+// ближайший существующий предок должен быть каталогом, доступным на запись.
+func validateStorageDir(dir string) error {
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("storage directory %s is not a directory", dir)
+			}
+			probe, err := os.CreateTemp(dir, ".shortener-*")
+			if err != nil {
+				return fmt.Errorf("storage directory %s is not writable: %w", dir, err)
+			}
+			probe.Close()
+			return os.Remove(probe.Name())
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("storage directory %s is not accessible: %w", dir, err)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return fmt.Errorf("storage directory %s is not accessible: %w", dir, err)
+		}
+		dir = parent
+	}
+}
+
+const (
+	migrateTimeout = time.Minute
+	// clicksFileSuffix — переходы хранятся рядом с файлом ссылок
+	clicksFileSuffix = ".clicks"
+)
+
+func (c *Config) InitRepository() error {
+	// База данных имеет приоритет над файловым хранилищем
+	if c.DatabaseDSN != "" {
+		conn, err := c.initPostgres()
 		if err != nil {
-			c.URLRepository = repository.NewInMemoryURLRepository()
-		} else {
-			c.URLRepository = fileRepo
+			return err
 		}
-	} else {
+		c.URLRepository = repository.NewPostgresURLRepository(conn)
+		c.ClickRepository = repository.NewPostgresClickRepository(conn)
+		return nil
+	}
+
+	// Хранилище в памяти — только явный выбор пустым -f: если файл не загрузился,
+	// сервер не должен молча стартовать без ссылок
+	if c.FileStoragePath == "" {
 		c.URLRepository = repository.NewInMemoryURLRepository()
+		c.ClickRepository = repository.NewInMemoryClickRepository()
+		return nil
+	}
+
+	fileRepo, err := repository.NewFileURLRepository(c.FileStoragePath, c.fileOptions())
+	if err != nil {
+		return fmt.Errorf("failed to open file storage: %w", err)
+	}
+	clickRepo, err := repository.NewFileClickRepository(c.FileStoragePath+clicksFileSuffix, c.FileCompactInterval)
+	if err != nil {
+		fileRepo.Close()
+		return fmt.Errorf("failed to open click storage: %w", err)
+	}
+	c.URLRepository = fileRepo
+	c.ClickRepository = clickRepo
+	return nil
+}
+
+// OpenURLRepository открывает только хранилище ссылок для утилит обслуживания. Пустой -f для них —
+// ошибка: работать с пустым хранилищем в памяти бессмысленно. С readOnly файловое хранилище открывается под разделяемой блокировкой и без фонового сжатия.
+func (c *Config) OpenURLRepository(readOnly bool) (repository.URLRepository, error) {
+	if c.DatabaseDSN != "" {
+		conn, err := c.initPostgres()
+		if err != nil {
+			return nil, err
+		}
+		return repository.NewPostgresURLRepository(conn), nil
+	}
+	if c.FileStoragePath != "" {
+		opts := c.fileOptions()
+		if readOnly {
+			opts.ReadOnly = true
+			opts.CompactInterval = 0
+		}
+		return repository.NewFileURLRepository(c.FileStoragePath, opts)
+	}
+	return nil, fmt.Errorf("storage is not configured (use -d or -f)")
+}
+
+func (c *Config) fileOptions() repository.FileOptions {
+	return repository.FileOptions{
+		SyncInterval:    c.FileSyncInterval,
+		CompactInterval: c.FileCompactInterval,
+	}
+}
+
+func (c *Config) initPostgres() (*sql.DB, error) {
+	conn, err := db.Open(c.DatabaseDSN)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), migrateTimeout)
+	defer cancel()
+
+	if err := db.Migrate(ctx, conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+	return conn, nil
+}
+
+// TrustedProxyList возвращает доверенные прокси списком для gin.Engine.SetTrustedProxies.
+func (c *Config) TrustedProxyList() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(c.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
 	}
+	return proxies
+}
+
+func (c *Config) TracingOptions() tracing.Options {
+	return tracing.Options{
+		Exporter:    c.TraceExporter,
+		File:        c.TraceFile,
+		Endpoint:    c.TraceEndpoint,
+		SampleRatio: c.TraceSampleRatio,
+	}
+}
+
+// AuthSecret возвращает ключ подписи cookie. Если ключ не задан, генерируется случайный,
+// и выданные cookie перестанут действовать после перезапуска.
+func (c *Config) AuthSecret() []byte {
+	if c.SecretKey != "" {
+		return []byte(c.SecretKey)
+	}
+
+	log.Printf("secret key is not configured, generating a random one; auth cookies will not survive restart")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatalf("failed to generate secret key: %v", err)
+	}
+	c.SecretKey = hex.EncodeToString(secret)
+	c.secretGenerated = true
+	return []byte(c.SecretKey)
+}
+
+// ClickIPKey возвращает ключ хеширования IP-адресов в статистике переходов. Ключ выводится
+// из SecretKey, но не совпадает с ним: хеш адреса не должен быть подписью, годной для cookie.
+func (c *Config) ClickIPKey() []byte {
+	mac := hmac.New(sha256.New, c.AuthSecret())
+	mac.Write([]byte("click-ip"))
+	if c.secretGenerated {
+		log.Printf("secret key is not configured; unique visitors will be counted anew after restart")
+	}
+	return mac.Sum(nil)
 }
 
 func (c *Config) Close() error {
-	if fileRepo, ok := c.URLRepository.(*repository.FileURLRepository); ok {
-		return fileRepo.Close()
+	var errs []error
+	// Переходы в PostgreSQL используют соединение хранилища ссылок, поэтому закрываются первыми
+	for _, repo := range []any{c.ClickRepository, c.URLRepository} {
+		if closer, ok := repo.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
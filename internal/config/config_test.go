@@ -0,0 +1,173 @@
+package config
+
+import (
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"url-shortener/internal/repository"
+)
+
+func load(t *testing.T, args []string, env map[string]string) (*Config, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("shortener", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return Load(fs, args, func(key string) string { return env[key] })
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, "config.json", `{
+		"server_address": "file:1",
+		"base_url": "http://file",
+		"reap_interval": "5m",
+		"click_buffer_size": 500,
+		"trace_sample_ratio": 0.5
+	}`)
+
+	cfg, err := load(t, []string{"-c", path, "-a", "flag:1"}, map[string]string{
+		"SERVER_ADDRESS": "env:1",
+		"BASE_URL":       "http://env",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "flag:1", cfg.ServerAddress)
+	assert.Equal(t, "http://env", cfg.BaseURL)
+	assert.Equal(t, 5*time.Minute, cfg.ReapInterval)
+	assert.Equal(t, 500, cfg.ClickBufferSize)
+	assert.Equal(t, 0.5, cfg.TraceSampleRatio)
+	assert.Equal(t, 10*time.Second, cfg.ShutdownTimeout)
+}
+
+func TestLoadYAMLFromEnv(t *testing.T) {
+	path := writeFile(t, "config.yaml", "server_address: yaml:1\nclick_buffer_size: 1000000\nshorten_rate_limit: 0\n")
+
+	cfg, err := load(t, nil, map[string]string{"CONFIG": path})
+	require.NoError(t, err)
+	assert.Equal(t, "yaml:1", cfg.ServerAddress)
+	assert.Equal(t, 1000000, cfg.ClickBufferSize)
+	assert.Equal(t, "0", cfg.ShortenRateLimit)
+}
+
+func TestLoadReportsAllErrors(t *testing.T) {
+	path := writeFile(t, "config.json", `{"unknown": 1, "reap_interval": "soon"}`)
+
+	_, err := load(t, []string{"-c", path}, map[string]string{"CLICK_BUFFER_SIZE": "many"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown option "unknown"`)
+	assert.Contains(t, err.Error(), "reap_interval")
+	assert.Contains(t, err.Error(), "CLICK_BUFFER_SIZE")
+
+	_, err = load(t, []string{"-c", writeFile(t, "config.toml", "")}, nil)
+	assert.ErrorContains(t, err, "unsupported config file format")
+	_, err = load(t, []string{"-c", writeFile(t, "config.json", `{"base_url": {"host": "x"}}`)}, nil)
+	assert.ErrorContains(t, err, "base_url must be a string")
+}
+
+func TestValidate(t *testing.T) {
+	cfg, err := load(t, []string{"-f", filepath.Join(t.TempDir(), "data", "urls.json")}, nil)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+
+	notDir := writeFile(t, "file", "")
+	cfg.ServerAddress = "no-port"
+	cfg.BaseURL = "localhost:8080"
+	cfg.FileStoragePath = filepath.Join(notDir, "urls.json")
+	cfg.ClickBufferSize = 0
+
+	err = cfg.Validate()
+	require.Error(t, err)
+	for _, problem := range []string{"invalid server address", "invalid base URL", "is not a directory", "click buffer size"} {
+		assert.ErrorContains(t, err, problem)
+	}
+
+	// С базой данных файловое хранилище не используется и не проверяется
+	cfg, err = load(t, []string{"-d", "postgres://localhost/db", "-f", filepath.Join(notDir, "urls.json")}, nil)
+	require.NoError(t, err)
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestValidateTLS(t *testing.T) {
+	cfg, err := load(t, []string{"-s", "-b", "http://localhost:8080", "-tls-cert", "missing.crt", "-http-redirect-address", "bad"}, nil)
+	require.NoError(t, err)
+
+	err = cfg.Validate()
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "certificate and key must be set together")
+	assert.ErrorContains(t, err, "invalid HTTP redirect address")
+	assert.Len(t, cfg.Warnings(), 1)
+
+	cfg, err = load(t, []string{"-http-redirect-address", ":80"}, map[string]string{"ENABLE_HTTPS": "false"})
+	require.NoError(t, err)
+	assert.ErrorContains(t, cfg.Validate(), "requires HTTPS")
+	assert.Empty(t, cfg.Warnings())
+}
+
+func TestOpenURLRepository(t *testing.T) {
+	cfg, err := load(t, []string{"-f", ""}, nil)
+	require.NoError(t, err)
+	_, err = cfg.OpenURLRepository(false)
+	assert.Error(t, err)
+
+	// Недоступный файл — ошибка, а не пустое хранилище в памяти
+	cfg, err = load(t, []string{"-f", writeFile(t, "urls.json", "not json\nat all\n")}, nil)
+	require.NoError(t, err)
+	_, err = cfg.OpenURLRepository(false)
+	assert.Error(t, err)
+
+	cfg, err = load(t, []string{"-f", filepath.Join(t.TempDir(), "urls.json")}, nil)
+	require.NoError(t, err)
+	repo, err := cfg.OpenURLRepository(false)
+	require.NoError(t, err)
+	assert.IsType(t, &repository.FileURLRepository{}, repo)
+
+	// Пока хранилище открыто на запись, другой процесс не может открыть его даже для чтения
+	_, err = cfg.OpenURLRepository(true)
+	assert.ErrorIs(t, err, repository.ErrLocked)
+	require.NoError(t, repo.(io.Closer).Close())
+
+	reader, err := cfg.OpenURLRepository(true)
+	require.NoError(t, err)
+	defer reader.(io.Closer).Close()
+	another, err := cfg.OpenURLRepository(true)
+	require.NoError(t, err)
+	require.NoError(t, another.(io.Closer).Close())
+	_, err = cfg.OpenURLRepository(false)
+	assert.ErrorIs(t, err, repository.ErrLocked)
+}
+
+func TestInitRepository(t *testing.T) {
+	// Поврежденный журнал — ошибка запуска, а не пустое хранилище
+	cfg, err := load(t, []string{"-f", writeFile(t, "urls.json", "not json\nat all\n")}, nil)
+	require.NoError(t, err)
+	assert.Error(t, cfg.InitRepository())
+	assert.Nil(t, cfg.URLRepository)
+
+	cfg, err = load(t, []string{"-f", ""}, nil)
+	require.NoError(t, err)
+	require.NoError(t, cfg.InitRepository())
+	assert.IsType(t, &repository.InMemoryURLRepository{}, cfg.URLRepository)
+
+	cfg, err = load(t, []string{"-f", filepath.Join(t.TempDir(), "urls.json")}, nil)
+	require.NoError(t, err)
+	require.NoError(t, cfg.InitRepository())
+	assert.IsType(t, &repository.FileURLRepository{}, cfg.URLRepository)
+	require.NoError(t, cfg.Close())
+}
+
+func TestClickIPKey(t *testing.T) {
+	cfg := &Config{SecretKey: "secret"}
+	key := cfg.ClickIPKey()
+	assert.Equal(t, key, (&Config{SecretKey: "secret"}).ClickIPKey())
+	assert.NotEqual(t, []byte("secret"), key)
+	assert.NotEqual(t, key, (&Config{SecretKey: "other"}).ClickIPKey())
+}
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"time"
+	"url-shortener/migrations"
+)
+
+const (
+	maxOpenConns    = 20
+	maxIdleConns    = 5
+	connMaxLifetime = 30 * time.Minute
+	connectTimeout  = 5 * time.Second
+)
+
+// Open открывает пул подключений к PostgreSQL и проверяет, что база доступна.
+func Open(dsn string) (*sql.DB, error) {
+	conn, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	conn.SetMaxOpenConns(maxOpenConns)
+	conn.SetMaxIdleConns(maxIdleConns)
+	conn.SetConnMaxLifetime(connMaxLifetime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+
+	if err := conn.PingContext(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	return conn, nil
+}
+
+// Migrate применяет к базе все миграции из каталога migrations.
+func Migrate(ctx context.Context, conn *sql.DB) error {
+	migrator, err := NewMigrator(conn, migrations.FS)
+	if err != nil {
+		return err
+	}
+	return migrator.Up(ctx)
+}
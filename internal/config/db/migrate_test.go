@@ -0,0 +1,79 @@
+package db
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"testing/fstest"
+	"time"
+	"url-shortener/migrations"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_add_index.up.sql":   {Data: []byte("CREATE INDEX i ON t (c);")},
+		"0002_add_index.down.sql": {Data: []byte("DROP INDEX i;")},
+		"0001_init.up.sql":        {Data: []byte("CREATE TABLE t (c TEXT);")},
+		"0001_init.down.sql":      {Data: []byte("DROP TABLE t;")},
+		"README.md":               {Data: []byte("ignored")},
+	}
+
+	migs, err := loadMigrations(fsys)
+	require.NoError(t, err)
+	require.Len(t, migs, 2)
+
+	assert.Equal(t, int64(1), migs[0].Version)
+	assert.Equal(t, "init", migs[0].Name)
+	assert.Equal(t, "DROP TABLE t;", migs[0].Down)
+	assert.Equal(t, int64(2), migs[1].Version)
+	assert.Equal(t, "CREATE INDEX i ON t (c);", migs[1].Up)
+}
+
+func TestLoadMigrationsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{
+			name: "missing up script",
+			fsys: fstest.MapFS{
+				"0001_init.down.sql": {Data: []byte("DROP TABLE t;")},
+			},
+		},
+		{
+			name: "conflicting names",
+			fsys: fstest.MapFS{
+				"0001_init.up.sql":  {Data: []byte("CREATE TABLE t (c TEXT);")},
+				"0001_other.up.sql": {Data: []byte("CREATE TABLE u (c TEXT);")},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := loadMigrations(test.fsys)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migs, err := loadMigrations(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, migs)
+
+	for i, mig := range migs {
+		assert.NotEmpty(t, mig.Down, "migration %d has no down script", mig.Version)
+		if i > 0 {
+			assert.Greater(t, mig.Version, migs[i-1].Version)
+		}
+	}
+}
+
+func TestCheckNotAhead(t *testing.T) {
+	m := &Migrator{migrations: []Migration{{Version: 1}, {Version: 2}}}
+
+	assert.NoError(t, m.checkNotAhead(nil))
+	assert.NoError(t, m.checkNotAhead(map[int64]time.Time{1: {}, 2: {}}))
+	assert.ErrorIs(t, m.checkNotAhead(map[int64]time.Time{3: {}}), ErrSchemaAhead)
+}
@@ -0,0 +1,198 @@
+package grpcserver
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"math"
+	"net"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener/internal/logging"
+	"url-shortener/internal/middleware"
+	"url-shortener/internal/ratelimit"
+	pb "url-shortener/pkg/shortener/v1"
+)
+
+// Ключи метаданных. В gRPC они всегда в нижнем регистре.
+const (
+	AuthorizationKey = "authorization"
+	AuthTokenKey     = "x-auth-token"
+	RequestIDKey     = "x-request-id"
+	RetryAfterKey    = "retry-after"
+
+	bearerPrefix = "Bearer "
+)
+
+type userKey struct{}
+
+// callerKey хранит *user, который authInterceptor заполняет для журнала вызовов.
+type callerKey struct{}
+
+type user struct {
+	id            string
+	authenticated bool
+}
+
+func userID(ctx context.Context) string {
+	u, _ := ctx.Value(userKey{}).(user)
+	return u.id
+}
+
+func authenticated(ctx context.Context) bool {
+	u, _ := ctx.Value(userKey{}).(user)
+	return u.authenticated
+}
+
+// loggingInterceptor принимает или создает идентификатор запроса, кладет в контекст логгер
+// с этим идентификатором и пишет одну запись на вызов.
+func loggingInterceptor(logger *zap.SugaredLogger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+
+		requestID, err := middleware.RequestID(firstValue(ctx, RequestIDKey))
+		if err != nil {
+			return nil, status.Error(codes.Internal, "internal server error")
+		}
+		_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDKey, requestID))
+
+		reqLogger := logger.With(zap.String("request_id", requestID))
+		ctx = logging.WithRequestID(ctx, requestID)
+		ctx = logging.WithContext(ctx, reqLogger)
+
+		// Пользователь определяется позже, в authInterceptor, поэтому место для него готовим заранее
+		var caller user
+		ctx = context.WithValue(ctx, callerKey{}, &caller)
+
+		resp, err := handler(ctx, req)
+
+		clientAddr := ""
+		if p, ok := peer.FromContext(ctx); ok {
+			clientAddr = p.Addr.String()
+		}
+		reqLogger.Infow("gRPC request",
+			zap.String("method", info.FullMethod),
+			zap.String("code", status.Code(err).String()),
+			zap.Duration("duration", time.Since(start)),
+			zap.String("client_addr", clientAddr),
+			zap.String("user_id", caller.id),
+		)
+		return resp, err
+	}
+}
+
+// recoveryInterceptor превращает панику обработчика в ошибку Internal, чтобы она не уронила сервер.
+func recoveryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logging.FromContext(ctx).Errorw("panic in gRPC handler",
+				"method", info.FullMethod, "panic", r, "stack", string(debug.Stack()))
+			err = status.Error(codes.Internal, "internal server error")
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// authInterceptor определяет пользователя по токену "Bearer <токен>" из метаданных authorization.
+// Если токена нет или он недействителен, выдается новый в заголовке ответа x-auth-token,
+// но вызов не считается аутентифицированным — так же, как AuthMiddleware в HTTP API.
+func authInterceptor(auth *middleware.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var u user
+		if token, ok := strings.CutPrefix(firstValue(ctx, AuthorizationKey), bearerPrefix); ok {
+			if id, err := auth.Verify(token); err == nil {
+				u = user{id: id, authenticated: true}
+			}
+		}
+		if !u.authenticated {
+			id, token, err := auth.Issue()
+			if err != nil {
+				return nil, status.Error(codes.Internal, "internal server error")
+			}
+			_ = grpc.SetHeader(ctx, metadata.Pairs(AuthTokenKey, token))
+			u = user{id: id}
+		}
+
+		if caller, ok := ctx.Value(callerKey{}).(*user); ok {
+			*caller = u
+		}
+		return handler(context.WithValue(ctx, userKey{}, u), req)
+	}
+}
+
+// RateLimits — ограничения частоты вызовов. Группы и корзины те же, что у HTTP API, поэтому
+// клиент расходует один лимит на оба API. Нулевое значение ничего не ограничивает.
+type RateLimits struct {
+	Store ratelimit.Store
+	// Shorten ограничивает Shorten и ShortenBatch
+	Shorten ratelimit.Limit
+	// Resolve ограничивает GetOriginal
+	Resolve ratelimit.Limit
+}
+
+// rateLimitInterceptor списывает вызов с корзины адреса клиента и, если вызов аутентифицирован,
+// с корзины пользователя — по тем же правилам, что ratelimit.Middleware.
+func rateLimitInterceptor(limits RateLimits) grpc.UnaryServerInterceptor {
+	groups := map[string]struct {
+		name  string
+		limit ratelimit.Limit
+	}{
+		pb.ShortenerService_Shorten_FullMethodName:      {"shorten", limits.Shorten},
+		pb.ShortenerService_ShortenBatch_FullMethodName: {"shorten", limits.Shorten},
+		pb.ShortenerService_GetOriginal_FullMethodName:  {"redirect", limits.Resolve},
+	}
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		group, ok := groups[info.FullMethod]
+		if !ok || limits.Store == nil || !group.limit.Enabled() {
+			return handler(ctx, req)
+		}
+
+		id := ""
+		if authenticated(ctx) {
+			id = userID(ctx)
+		}
+		res, err := ratelimit.TakeAll(ctx, limits.Store, group.limit, ratelimit.ClientKeys(group.name, peerHost(ctx), id)...)
+		if err != nil {
+			// Недоступность хранилища лимитов не должна останавливать сервис
+			logging.FromContext(ctx).Errorw("rate limit store failed", "error", err)
+			return handler(ctx, req)
+		}
+		if !res.Allowed {
+			retryAfter := strconv.Itoa(int(math.Ceil(res.RetryAfter.Seconds())))
+			_ = grpc.SetHeader(ctx, metadata.Pairs(RetryAfterKey, retryAfter))
+			return nil, status.Error(codes.ResourceExhausted, "too many requests")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// peerHost возвращает адрес клиента без порта, чтобы все соединения клиента делили одну корзину.
+func peerHost(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	addr := p.Addr.String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+func firstValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
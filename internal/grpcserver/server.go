@@ -0,0 +1,181 @@
+// Package grpcserver реализует gRPC API сервиса поверх service.URLService.
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+	"url-shortener/internal/middleware"
+	"url-shortener/internal/model"
+	"url-shortener/internal/repository"
+	"url-shortener/internal/service"
+	pb "url-shortener/pkg/shortener/v1"
+)
+
+type server struct {
+	pb.UnimplementedShortenerServiceServer
+	service service.URLService
+}
+
+// New создает gRPC-сервер с зарегистрированным ShortenerService. Интерцепторы пишут журнал
+// вызовов в logger, перехватывают паники, определяют пользователя по токену из метаданных
+// и ограничивают частоту вызовов лимитами limits.
+func New(svc service.URLService, auth *middleware.Authenticator, logger *zap.SugaredLogger, limits RateLimits, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts, grpc.ChainUnaryInterceptor(
+		loggingInterceptor(logger),
+		recoveryInterceptor,
+		authInterceptor(auth),
+		rateLimitInterceptor(limits),
+	))
+	s := grpc.NewServer(opts...)
+	pb.RegisterShortenerServiceServer(s, &server{service: svc})
+	return s
+}
+
+func (s *server) Shorten(ctx context.Context, req *pb.ShortenRequest) (*pb.ShortenResponse, error) {
+	originalURL := strings.TrimSpace(req.GetUrl())
+	if originalURL == "" {
+		return nil, status.Error(codes.InvalidArgument, "url cannot be empty")
+	}
+
+	opts := service.ShortenOptions{
+		UserID: userID(ctx),
+		Alias:  req.GetAlias(),
+	}
+	if req.ExpiresAt != nil {
+		if err := req.ExpiresAt.CheckValid(); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid expires_at: %v", err)
+		}
+		expiresAt := req.ExpiresAt.AsTime()
+		opts.ExpiresAt = &expiresAt
+	}
+	if req.Ttl != nil {
+		if err := req.Ttl.CheckValid(); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid ttl: %v", err)
+		}
+		opts.TTL = req.Ttl.AsDuration()
+	}
+
+	url, err := s.service.ShortenURL(ctx, originalURL, opts)
+	existed := repository.IsConflict(err)
+	if err != nil && !existed {
+		return nil, statusError(err)
+	}
+	return &pb.ShortenResponse{Id: url.ID, ShortUrl: url.Short, Existed: existed}, nil
+}
+
+func (s *server) ShortenBatch(ctx context.Context, req *pb.ShortenBatchRequest) (*pb.ShortenBatchResponse, error) {
+	if len(req.GetItems()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "batch cannot be empty")
+	}
+	if len(req.GetItems()) > service.MaxBatchURLs {
+		return nil, status.Errorf(codes.InvalidArgument, "too many urls, at most %d allowed", service.MaxBatchURLs)
+	}
+	items := make([]model.BatchRequestItem, 0, len(req.GetItems()))
+	for _, item := range req.GetItems() {
+		originalURL := strings.TrimSpace(item.GetOriginalUrl())
+		if item.GetCorrelationId() == "" || originalURL == "" {
+			return nil, status.Error(codes.InvalidArgument, "every item needs correlation_id and original_url")
+		}
+		items = append(items, model.BatchRequestItem{
+			CorrelationID: item.GetCorrelationId(),
+			OriginalURL:   originalURL,
+		})
+	}
+
+	result, err := s.service.ShortenBatch(ctx, items, userID(ctx))
+	existed := repository.IsConflict(err)
+	if err != nil && !existed {
+		return nil, statusError(err)
+	}
+
+	resp := &pb.ShortenBatchResponse{
+		Items:   make([]*pb.BatchResult, 0, len(result)),
+		Existed: existed,
+	}
+	for _, item := range result {
+		resp.Items = append(resp.Items, &pb.BatchResult{CorrelationId: item.CorrelationID, ShortUrl: item.ShortURL})
+	}
+	return resp, nil
+}
+
+// GetOriginal не учитывается в статистике переходов: его вызывают сервисы, а не посетители ссылки.
+func (s *server) GetOriginal(ctx context.Context, req *pb.GetOriginalRequest) (*pb.GetOriginalResponse, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id cannot be empty")
+	}
+
+	original, err := s.service.GetOriginalURL(ctx, req.GetId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if original == "" {
+		return nil, status.Error(codes.NotFound, "URL not found")
+	}
+	return &pb.GetOriginalResponse{OriginalUrl: original}, nil
+}
+
+func (s *server) ListUserURLs(ctx context.Context, req *pb.ListUserURLsRequest) (*pb.ListUserURLsResponse, error) {
+	if !authenticated(ctx) {
+		return nil, status.Error(codes.Unauthenticated, "authentication required")
+	}
+	limit := int(req.GetLimit())
+	if limit == 0 {
+		limit = service.DefaultUserURLsLimit
+	}
+	if limit < 1 || limit > service.MaxUserURLsLimit {
+		return nil, status.Errorf(codes.InvalidArgument, "limit must be between 1 and %d", service.MaxUserURLsLimit)
+	}
+
+	urls, next, err := s.service.GetUserURLs(ctx, userID(ctx), req.GetCursor(), limit)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	resp := &pb.ListUserURLsResponse{
+		Urls:       make([]*pb.UserURL, 0, len(urls)),
+		NextCursor: next,
+	}
+	for _, url := range urls {
+		resp.Urls = append(resp.Urls, &pb.UserURL{ShortUrl: url.ShortURL, OriginalUrl: url.OriginalURL})
+	}
+	return resp, nil
+}
+
+func (s *server) DeleteUserURLs(ctx context.Context, req *pb.DeleteUserURLsRequest) (*pb.DeleteUserURLsResponse, error) {
+	if !authenticated(ctx) {
+		return nil, status.Error(codes.Unauthenticated, "authentication required")
+	}
+	if len(req.GetIds()) > service.MaxDeleteIDs {
+		return nil, status.Errorf(codes.InvalidArgument, "too many ids, at most %d allowed", service.MaxDeleteIDs)
+	}
+	if err := s.service.DeleteUserURLs(ctx, userID(ctx), req.GetIds()); err != nil {
+		return nil, statusError(err)
+	}
+	return &pb.DeleteUserURLsResponse{}, nil
+}
+
+// statusError переводит ошибки сервиса в статусы gRPC так же, как HTTP API переводит их в коды ответа.
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return status.FromContextError(err).Err()
+	case errors.Is(err, service.ErrInvalidAlias), errors.Is(err, service.ErrInvalidExpiry),
+		errors.Is(err, repository.ErrInvalidCursor), errors.Is(err, repository.ErrInvalidLimit):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrAliasTaken):
+		return status.Error(codes.AlreadyExists, "alias already taken")
+	case errors.Is(err, service.ErrDeleted), errors.Is(err, service.ErrExpired):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrShuttingDown):
+		return status.Error(codes.Unavailable, "service is shutting down")
+	case errors.Is(err, service.ErrOverloaded):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return status.Error(codes.Internal, "internal server error")
+	}
+}
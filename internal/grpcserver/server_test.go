@@ -0,0 +1,161 @@
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"net"
+	"testing"
+	"time"
+	"url-shortener/internal/middleware"
+	"url-shortener/internal/ratelimit"
+	"url-shortener/internal/repository"
+	"url-shortener/internal/service"
+	pb "url-shortener/pkg/shortener/v1"
+)
+
+func setup(t *testing.T, limits RateLimits) (pb.ShortenerServiceClient, *observer.ObservedLogs) {
+	t.Helper()
+	core, logs := observer.New(zap.InfoLevel)
+	svc := service.NewURLService(repository.NewInMemoryURLRepository(), "http://localhost:8080")
+	srv := New(svc, middleware.NewAuthenticator([]byte("secret")), zap.New(core).Sugar(), limits)
+
+	lis := bufconn.Listen(1 << 20)
+	go srv.Serve(lis)
+	t.Cleanup(func() {
+		srv.Stop()
+		svc.Shutdown(context.Background())
+	})
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewShortenerServiceClient(conn), logs
+}
+
+func TestShortenAndGetOriginal(t *testing.T) {
+	client, logs := setup(t, RateLimits{})
+	ctx := context.Background()
+
+	var header metadata.MD
+	resp, err := client.Shorten(ctx, &pb.ShortenRequest{Url: "https://example.com", Ttl: durationpb.New(time.Hour)}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.False(t, resp.Existed)
+	assert.Equal(t, "http://localhost:8080/"+resp.Id, resp.ShortUrl)
+	require.Len(t, header.Get(AuthTokenKey), 1)
+	require.Len(t, header.Get(RequestIDKey), 1)
+
+	again, err := client.Shorten(ctx, &pb.ShortenRequest{Url: "https://example.com"})
+	require.NoError(t, err)
+	assert.True(t, again.Existed)
+	assert.Equal(t, resp.ShortUrl, again.ShortUrl)
+
+	original, err := client.GetOriginal(ctx, &pb.GetOriginalRequest{Id: resp.Id})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", original.OriginalUrl)
+
+	_, err = client.GetOriginal(ctx, &pb.GetOriginalRequest{Id: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.Shorten(ctx, &pb.ShortenRequest{Url: "https://example.com/x", Alias: "x"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.Shorten(ctx, &pb.ShortenRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// Запись журнала несет тот же идентификатор запроса, что вернулся клиенту
+	entries := logs.FilterMessage("gRPC request").All()
+	require.NotEmpty(t, entries)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, header.Get(RequestIDKey)[0], fields["request_id"])
+	assert.Equal(t, pb.ShortenerService_Shorten_FullMethodName, fields["method"])
+	assert.Equal(t, "OK", fields["code"])
+	assert.NotEmpty(t, fields["user_id"])
+}
+
+func TestUserURLs(t *testing.T) {
+	client, _ := setup(t, RateLimits{})
+	ctx := context.Background()
+
+	_, err := client.ListUserURLs(ctx, &pb.ListUserURLsRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	var header metadata.MD
+	batch, err := client.ShortenBatch(ctx, &pb.ShortenBatchRequest{Items: []*pb.BatchItem{
+		{CorrelationId: "1", OriginalUrl: " https://one.example"},
+		{CorrelationId: "2", OriginalUrl: "https://two.example"},
+	}}, grpc.Header(&header))
+	require.NoError(t, err)
+	require.Len(t, batch.Items, 2)
+
+	items := make([]*pb.BatchItem, service.MaxBatchURLs+1)
+	for i := range items {
+		items[i] = &pb.BatchItem{CorrelationId: "1", OriginalUrl: "https://too-many.example"}
+	}
+	_, err = client.ShortenBatch(ctx, &pb.ShortenBatchRequest{Items: items})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// Пробелы вокруг URL отбрасываются перед сохранением
+	again, err := client.Shorten(ctx, &pb.ShortenRequest{Url: "https://one.example"})
+	require.NoError(t, err)
+	assert.True(t, again.Existed)
+
+	authCtx := metadata.AppendToOutgoingContext(ctx, AuthorizationKey, bearerPrefix+header.Get(AuthTokenKey)[0])
+	list, err := client.ListUserURLs(authCtx, &pb.ListUserURLsRequest{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, list.Urls, 1)
+	assert.NotEmpty(t, list.NextCursor)
+
+	_, err = client.ListUserURLs(authCtx, &pb.ListUserURLsRequest{Cursor: "bad"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.DeleteUserURLs(ctx, &pb.DeleteUserURLsRequest{Ids: []string{"x"}})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = client.DeleteUserURLs(authCtx, &pb.DeleteUserURLsRequest{Ids: []string{"x"}})
+	assert.NoError(t, err)
+}
+
+func TestRecoveryInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/test"}
+	_, err := recoveryInterceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		panic("boom")
+	})
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestRateLimit(t *testing.T) {
+	client, _ := setup(t, RateLimits{
+		Store:   ratelimit.NewMemoryStore(),
+		Shorten: ratelimit.Limit{Rate: 0.001, Burst: 2},
+	})
+	ctx := context.Background()
+
+	// Каждый вызов без токена получает новый, но адрес клиента общий, поэтому смена токена не помогает
+	for i := 0; i < 2; i++ {
+		_, err := client.Shorten(ctx, &pb.ShortenRequest{Url: fmt.Sprintf("https://example.com/%d", i)})
+		require.NoError(t, err)
+	}
+	var header metadata.MD
+	_, err := client.Shorten(ctx, &pb.ShortenRequest{Url: "https://example.com/limited"}, grpc.Header(&header))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.NotEmpty(t, header.Get(RetryAfterKey))
+
+	_, err = client.ShortenBatch(ctx, &pb.ShortenBatchRequest{Items: []*pb.BatchItem{{CorrelationId: "1", OriginalUrl: "https://example.com/batch"}}})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// Переходы ограничены своей группой, здесь она выключена
+	_, err = client.GetOriginal(ctx, &pb.GetOriginalRequest{Id: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -1,14 +1,32 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
+	"url-shortener/internal/middleware"
 	"url-shortener/internal/model"
+	"url-shortener/internal/repository"
 	"url-shortener/internal/service"
 )
 
+const (
+	nextCursorHeader = "X-Next-Cursor"
+
+	// maxBatchBodySize ограничивает тело пакетного запроса, чтобы его не приходилось читать целиком
+	// до проверки числа URL
+	maxBatchBodySize = 4 << 20
+
+	// statusClientClosedRequest — нестандартный код nginx: клиент отключился, не дождавшись ответа
+	statusClientClosedRequest = 499
+)
+
 type Handlers struct {
 	service service.URLService
 }
@@ -36,14 +54,23 @@ func (h *Handlers) ShortenURL(c *gin.Context) {
 		return
 	}
 
-	url, err := h.service.ShortenURL(originalURL)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	opts := service.ShortenOptions{
+		UserID: middleware.UserID(c),
+		Alias:  c.Query("alias"),
+	}
+	if err := parseExpiry(&opts, c.Query("expires_at"), c.Query("ttl")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	url, err := h.service.ShortenURL(c.Request.Context(), originalURL, opts)
+	status, ok := shortenStatus(c, err)
+	if !ok {
 		return
 	}
 
 	c.Header("Content-Type", "text/plain")
-	c.String(http.StatusCreated, url.Short)
+	c.String(status, url.Short)
 }
 
 func (h *Handlers) GetOriginalURL(c *gin.Context) {
@@ -54,7 +81,18 @@ func (h *Handlers) GetOriginalURL(c *gin.Context) {
 		return
 	}
 
-	originalURL, err := h.service.GetOriginalURL(id)
+	originalURL, err := h.service.GetOriginalURL(c.Request.Context(), id)
+	if errors.Is(err, service.ErrDeleted) {
+		c.JSON(http.StatusGone, gin.H{"error": "Url has been deleted"})
+		return
+	}
+	if errors.Is(err, service.ErrExpired) {
+		c.JSON(http.StatusGone, gin.H{"error": "Url has expired"})
+		return
+	}
+	if contextError(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid server error"})
 		return
@@ -65,6 +103,12 @@ func (h *Handlers) GetOriginalURL(c *gin.Context) {
 		return
 	}
 
+	h.service.RecordClick(c.Request.Context(), id, service.ClickInfo{
+		Referrer:  c.Request.Referer(),
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	})
+
 	c.Header("Location", originalURL)
 	// если я правильно понял задания и здесь не нужен c.Redirect
 	c.String(http.StatusTemporaryRedirect, originalURL)
@@ -92,9 +136,19 @@ func (h *Handlers) ShortenJSONUrl(c *gin.Context) {
 		return
 	}
 
-	url, err := h.service.ShortenURL(req.URL)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	opts := service.ShortenOptions{
+		UserID:    middleware.UserID(c),
+		Alias:     req.Alias,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := parseExpiry(&opts, "", req.TTL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	url, err := h.service.ShortenURL(c.Request.Context(), req.URL, opts)
+	status, ok := shortenStatus(c, err)
+	if !ok {
 		return
 	}
 
@@ -102,7 +156,7 @@ func (h *Handlers) ShortenJSONUrl(c *gin.Context) {
 		Result: url.Short,
 	}
 	c.Header("Content-Type", "application/json")
-	c.Status(http.StatusCreated)
+	c.Status(status)
 
 	enc := json.NewEncoder(c.Writer)
 	if err := enc.Encode(resp); err != nil {
@@ -112,3 +166,237 @@ func (h *Handlers) ShortenJSONUrl(c *gin.Context) {
 
 	//c.JSON(http.StatusCreated, resp)
 }
+
+func (h *Handlers) ShortenBatch(c *gin.Context) {
+	if c.ContentType() != "application/json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid content type"})
+		return
+	}
+
+	var req []model.BatchRequestItem
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxBatchBodySize)
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format"})
+		return
+	}
+	if len(req) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Batch cannot be empty"})
+		return
+	}
+	if len(req) > service.MaxBatchURLs {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Too many URLs, at most %d allowed", service.MaxBatchURLs)})
+		return
+	}
+	for i := range req {
+		// Сохраняется тот же URL, что проверен, иначе " https://a" и "https://a" стали бы разными ссылками
+		req[i].OriginalURL = strings.TrimSpace(req[i].OriginalURL)
+		if req[i].CorrelationID == "" || req[i].OriginalURL == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format"})
+			return
+		}
+	}
+
+	status := http.StatusCreated
+	resp, err := h.service.ShortenBatch(c.Request.Context(), req, middleware.UserID(c))
+	if err != nil {
+		if contextError(c, err) {
+			return
+		}
+		if !repository.IsConflict(err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		status = http.StatusConflict
+	}
+
+	c.JSON(status, resp)
+}
+
+// GetUserURLs отдает ссылки текущего пользователя постранично. Размер страницы задается
+// параметром limit, следующая страница запрашивается с курсором из заголовка X-Next-Cursor.
+func (h *Handlers) GetUserURLs(c *gin.Context) {
+	limit := service.DefaultUserURLsLimit
+	if rawLimit := c.Query("limit"); rawLimit != "" {
+		parsed, err := strconv.Atoi(rawLimit)
+		if err != nil || parsed < 1 || parsed > service.MaxUserURLsLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = parsed
+	}
+
+	urls, next, err := h.service.GetUserURLs(c.Request.Context(), middleware.UserID(c), c.Query("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		if contextError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	if len(urls) == 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	if next != "" {
+		c.Header(nextCursorHeader, next)
+	}
+	c.JSON(http.StatusOK, urls)
+}
+
+// GetURLStats отдает владельцу статистику переходов по ссылке. Интервал задается параметрами
+// from и to в формате RFC 3339, размер топов источников и user agent — параметром top.
+func (h *Handlers) GetURLStats(c *gin.Context) {
+	var query service.StatsQuery
+	for param, dst := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param})
+			return
+		}
+		*dst = t
+	}
+	if rawTop := c.Query("top"); rawTop != "" {
+		top, err := strconv.Atoi(rawTop)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid top"})
+			return
+		}
+		query.Top = top
+	}
+
+	stats, err := h.service.GetURLStats(c.Request.Context(), middleware.UserID(c), c.Param("id"), query)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, stats)
+	case errors.Is(err, service.ErrInvalidStatsQuery):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Url not found"})
+	case errors.Is(err, service.ErrStatsUnavailable):
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Statistics are not enabled"})
+	case contextError(c, err):
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
+}
+
+// GetServiceStats отдает число ссылок и пользователей. Доступ ограничивается middleware.TrustedSubnet.
+func (h *Handlers) GetServiceStats(c *gin.Context) {
+	stats, err := h.service.GetServiceStats(c.Request.Context())
+	if contextError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// DeleteUserURLs принимает JSON-массив ID ссылок текущего пользователя и удаляет их в фоне.
+func (h *Handlers) DeleteUserURLs(c *gin.Context) {
+	if c.ContentType() != "application/json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid content type"})
+		return
+	}
+
+	var ids []string
+	if err := json.NewDecoder(c.Request.Body).Decode(&ids); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format"})
+		return
+	}
+	if len(ids) > service.MaxDeleteIDs {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Too many IDs, at most %d allowed", service.MaxDeleteIDs)})
+		return
+	}
+
+	err := h.service.DeleteUserURLs(c.Request.Context(), middleware.UserID(c), ids)
+	if contextError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service unavailable"})
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
+
+func (h *Handlers) Ping(c *gin.Context) {
+	if err := h.service.Ping(c.Request.Context()); err != nil {
+		if contextError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database unavailable"})
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// shortenStatus выбирает код ответа по результату сокращения. Если запрос завершился
+// ошибкой, ответ уже записан и возвращается false.
+func shortenStatus(c *gin.Context, err error) (int, bool) {
+	switch {
+	case err == nil:
+		return http.StatusCreated, true
+	case repository.IsConflict(err):
+		return http.StatusConflict, true
+	case errors.Is(err, service.ErrInvalidAlias):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidExpiry):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrAliasTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": "Alias already taken"})
+	case contextError(c, err):
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
+	return 0, false
+}
+
+// parseExpiry разбирает срок действия ссылки: expiresAt в формате RFC 3339, ttl — длительность Go (например, 72h).
+func parseExpiry(opts *service.ShortenOptions, expiresAt, ttl string) error {
+	if expiresAt != "" {
+		t, err := time.Parse(time.RFC3339, expiresAt)
+		if err != nil {
+			return fmt.Errorf("%w: expires_at must be in RFC 3339 format", service.ErrInvalidExpiry)
+		}
+		opts.ExpiresAt = &t
+	}
+	if ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			return fmt.Errorf("%w: ttl must be a duration like 72h", service.ErrInvalidExpiry)
+		}
+		opts.TTL = d
+	}
+	return nil
+}
+
+// contextError отвечает на ошибки истечения срока и отмены запроса и возвращает true.
+// Для остальных ошибок ничего не пишет и возвращает false.
+func contextError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Request timed out"})
+	case errors.Is(err, context.Canceled):
+		// Отвечать уже некому, код нужен только для журнала и метрик
+		c.Status(statusClientClosedRequest)
+	default:
+		return false
+	}
+	return true
+}
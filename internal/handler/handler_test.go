@@ -1,20 +1,61 @@
 package handler
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"url-shortener/internal/middleware"
 	"url-shortener/internal/model"
+	"url-shortener/internal/repository"
+	"url-shortener/internal/service"
 )
 
-type MockService struct{}
+type MockService struct {
+	pingErr  error
+	userURLs map[string][]model.UserURL
+	deleted  []model.URLDeletion
+	clicks   []string
+	batch    []model.BatchRequestItem
+}
+
+var (
+	testAuth         = middleware.NewAuthenticator([]byte("test-secret"))
+	_, testSubnet, _ = net.ParseCIDR("10.0.0.0/8")
+)
 
-func (m *MockService) ShortenURL(original string) (*model.URL, error) {
+func (m *MockService) ShortenURL(ctx context.Context, original string, opts service.ShortenOptions) (*model.URL, error) {
+	if opts.Alias == "taken" {
+		return nil, service.ErrAliasTaken
+	}
+	if opts.Alias == "x" {
+		return nil, fmt.Errorf("%w: too short", service.ErrInvalidAlias)
+	}
+	if opts.ExpiresAt != nil && opts.TTL != 0 {
+		return nil, fmt.Errorf("%w: only one of expires_at and ttl can be set", service.ErrInvalidExpiry)
+	}
+	if opts.Alias != "" {
+		return &model.URL{
+			ID:       opts.Alias,
+			Original: original,
+			Short:    "http://localhost:8080/" + opts.Alias,
+		}, nil
+	}
+	if original == "https://existing.example" {
+		existing := &model.URL{
+			ID:       "exist1",
+			Original: original,
+			Short:    "http://localhost:8080/exist1",
+		}
+		return existing, &repository.ErrConflict{URL: existing}
+	}
 	return &model.URL{
 		ID:       "abc123",
 		Original: original,
@@ -22,20 +63,99 @@ func (m *MockService) ShortenURL(original string) (*model.URL, error) {
 	}, nil
 }
 
-func (m *MockService) GetOriginalURL(id string) (string, error) {
+func (m *MockService) ShortenBatch(ctx context.Context, items []model.BatchRequestItem, userID string) ([]model.BatchResponseItem, error) {
+	m.batch = items
+	result := make([]model.BatchResponseItem, 0, len(items))
+	for _, item := range items {
+		result = append(result, model.BatchResponseItem{
+			CorrelationID: item.CorrelationID,
+			ShortURL:      "http://localhost:8080/" + item.CorrelationID,
+		})
+	}
+	return result, nil
+}
+
+func (m *MockService) GetOriginalURL(ctx context.Context, id string) (string, error) {
 	if id == "nonexistent" {
 		return "", errors.New("not found")
 	}
+	if id == "deleted" {
+		return "", service.ErrDeleted
+	}
+	if id == "expired" {
+		return "", service.ErrExpired
+	}
+	if id == "slow" {
+		return "", fmt.Errorf("failed to query URL: %w", context.DeadlineExceeded)
+	}
+	if id == "canceled" {
+		return "", context.Canceled
+	}
 	return "https://example.com", nil
 }
 
+func (m *MockService) GetUserURLs(ctx context.Context, userID, cursor string, limit int) ([]model.UserURL, string, error) {
+	if cursor != "" {
+		return nil, "", repository.ErrInvalidCursor
+	}
+	urls := m.userURLs[userID]
+	if len(urls) > limit {
+		return urls[:limit], "next-page", nil
+	}
+	return urls, "", nil
+}
+
+func (m *MockService) DeleteUserURLs(ctx context.Context, userID string, ids []string) error {
+	for _, id := range ids {
+		m.deleted = append(m.deleted, model.URLDeletion{UserID: userID, ID: id})
+	}
+	return nil
+}
+
+func (m *MockService) GetURLStats(ctx context.Context, userID, id string, query service.StatsQuery) (*model.ClickStats, error) {
+	if id != "abc123" {
+		return nil, service.ErrNotFound
+	}
+	if query.Top > 100 {
+		return nil, fmt.Errorf("%w: top must be between 1 and 100", service.ErrInvalidStatsQuery)
+	}
+	return &model.ClickStats{URLID: id, From: query.From, To: query.To, TotalClicks: 42}, nil
+}
+
+func (m *MockService) GetServiceStats(ctx context.Context) (*model.ServiceStats, error) {
+	return &model.ServiceStats{URLs: 3, Users: 2}, nil
+}
+
+func (m *MockService) RecordClick(ctx context.Context, id string, info service.ClickInfo) {
+	m.clicks = append(m.clicks, id)
+}
+
+func (m *MockService) DroppedClicks() int64 {
+	return 0
+}
+
+func (m *MockService) Ping(ctx context.Context) error {
+	return m.pingErr
+}
+
+func (m *MockService) Shutdown(ctx context.Context) error {
+	return nil
+}
+
 func setupGinRouter(handler *Handlers) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
+	router.Use(middleware.AuthMiddleware(testAuth))
 
 	router.POST("/", handler.ShortenURL)
+	router.GET("/ping", handler.Ping)
 	router.GET("/:id", handler.GetOriginalURL)
 	router.POST("/api/shorten", handler.ShortenJSONUrl)
+	router.POST("/api/shorten/batch", handler.ShortenBatch)
+	router.GET("/api/user/urls", middleware.RequireAuth(), handler.GetUserURLs)
+	router.DELETE("/api/user/urls", middleware.RequireAuth(), handler.DeleteUserURLs)
+	router.GET("/api/urls/:id/stats", middleware.RequireAuth(), handler.GetURLStats)
+	router.GET("/api/internal/stats", middleware.TrustedSubnet(testSubnet), handler.GetServiceStats)
 
 	return router
 }
@@ -150,6 +270,19 @@ func TestShortenUrlMoke(t *testing.T) {
 				body:        "http://localhost:8080/abc123",
 			},
 		},
+		{
+			name:   "already shortened url",
+			method: "POST",
+			body:   "https://existing.example",
+			headers: map[string]string{
+				"Content-Type": "text/plain",
+			},
+			want: want{
+				contentType: "text/plain",
+				statusCode:  http.StatusConflict,
+				body:        "http://localhost:8080/exist1",
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -214,6 +347,41 @@ func TestGetOriginalURL(t *testing.T) {
 				body:       `{"error":"Invalid server error"}`,
 			},
 		},
+		{
+			name:   "deleted url",
+			method: "GET",
+			url:    "/deleted",
+			want: want{
+				statusCode: http.StatusGone,
+				body:       `{"error":"Url has been deleted"}`,
+			},
+		},
+		{
+			name:   "expired url",
+			method: "GET",
+			url:    "/expired",
+			want: want{
+				statusCode: http.StatusGone,
+				body:       `{"error":"Url has expired"}`,
+			},
+		},
+		{
+			name:   "storage timeout",
+			method: "GET",
+			url:    "/slow",
+			want: want{
+				statusCode: http.StatusGatewayTimeout,
+				body:       `{"error":"Request timed out"}`,
+			},
+		},
+		{
+			name:   "client gone",
+			method: "GET",
+			url:    "/canceled",
+			want: want{
+				statusCode: statusClientClosedRequest,
+			},
+		},
 		{
 			name:   "invalid method POST",
 			method: "POST",
@@ -256,6 +424,23 @@ func TestGetOriginalURL(t *testing.T) {
 	}
 }
 
+func TestGetOriginalURLRecordsClick(t *testing.T) {
+	mockService := &MockService{}
+	router := setupGinRouter(NewHandler(mockService))
+
+	req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/deleted", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusGone, w.Code)
+
+	assert.Equal(t, []string{"abc123"}, mockService.clicks)
+}
+
 func TestShortenJsonURL(t *testing.T) {
 	type want struct {
 		contentType string
@@ -378,6 +563,19 @@ func TestShortenJsonURLMoke(t *testing.T) {
 				body:        `{"result":"http://localhost:8080/abc123"}`,
 			},
 		},
+		{
+			name:   "already shortened url",
+			method: "POST",
+			body:   `{"url": "https://existing.example"}`,
+			headers: map[string]string{
+				"Content-Type": "application/json",
+			},
+			want: want{
+				contentType: "application/json",
+				statusCode:  http.StatusConflict,
+				body:        `{"result":"http://localhost:8080/exist1"}`,
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -409,3 +607,536 @@ func TestShortenJsonURLMoke(t *testing.T) {
 		})
 	}
 }
+
+func TestShortenBatch(t *testing.T) {
+	type want struct {
+		statusCode int
+		body       string
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		headers map[string]string
+		want    want
+	}{
+		{
+			name: "success batch",
+			body: `[{"correlation_id":"1","original_url":"https://a.example"},{"correlation_id":"2","original_url":"https://b.example"}]`,
+			headers: map[string]string{
+				"Content-Type": "application/json",
+			},
+			want: want{
+				statusCode: http.StatusCreated,
+				body:       `[{"correlation_id":"1","short_url":"http://localhost:8080/1"},{"correlation_id":"2","short_url":"http://localhost:8080/2"}]`,
+			},
+		},
+		{
+			name: "invalid content type",
+			body: `[{"correlation_id":"1","original_url":"https://a.example"}]`,
+			headers: map[string]string{
+				"Content-Type": "text/plain",
+			},
+			want: want{
+				statusCode: http.StatusBadRequest,
+				body:       `{"error":"Invalid content type"}`,
+			},
+		},
+		{
+			name: "empty batch",
+			body: `[]`,
+			headers: map[string]string{
+				"Content-Type": "application/json",
+			},
+			want: want{
+				statusCode: http.StatusBadRequest,
+				body:       `{"error":"Batch cannot be empty"}`,
+			},
+		},
+		{
+			name: "missing original url",
+			body: `[{"correlation_id":"1"}]`,
+			headers: map[string]string{
+				"Content-Type": "application/json",
+			},
+			want: want{
+				statusCode: http.StatusBadRequest,
+				body:       `{"error":"Invalid JSON format"}`,
+			},
+		},
+		{
+			name: "too many urls",
+			body: "[" + strings.Repeat(`{"correlation_id":"1","original_url":"https://a.example"},`, service.MaxBatchURLs) +
+				`{"correlation_id":"1","original_url":"https://a.example"}]`,
+			headers: map[string]string{
+				"Content-Type": "application/json",
+			},
+			want: want{
+				statusCode: http.StatusBadRequest,
+				body:       fmt.Sprintf(`{"error":"Too many URLs, at most %d allowed"}`, service.MaxBatchURLs),
+			},
+		},
+		{
+			name: "body too large",
+			body: `[{"correlation_id":"1","original_url":"https://a.example/` + strings.Repeat("a", maxBatchBodySize) + `"}]`,
+			headers: map[string]string{
+				"Content-Type": "application/json",
+			},
+			want: want{
+				statusCode: http.StatusRequestEntityTooLarge,
+				body:       `{"error":"Request body too large"}`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := NewHandler(&MockService{})
+			router := setupGinRouter(h)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader(test.body))
+			for k, v := range test.headers {
+				req.Header.Set(k, v)
+			}
+
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, test.want.statusCode, res.StatusCode)
+
+			bodyBytes, _ := io.ReadAll(res.Body)
+			assert.Equal(t, test.want.body, strings.TrimSpace(string(bodyBytes)))
+		})
+	}
+}
+
+func TestShortenBatchTrimsURLs(t *testing.T) {
+	mockService := &MockService{}
+	router := setupGinRouter(NewHandler(mockService))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch",
+		strings.NewReader(`[{"correlation_id":"1","original_url":"  https://a.example\n"}]`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, []model.BatchRequestItem{{CorrelationID: "1", OriginalURL: "https://a.example"}}, mockService.batch)
+}
+
+func TestGetUserURLs(t *testing.T) {
+	type want struct {
+		statusCode int
+		body       string
+		nextCursor string
+	}
+
+	mockService := &MockService{
+		userURLs: map[string][]model.UserURL{
+			"user-1": {
+				{ShortURL: "http://localhost:8080/a", OriginalURL: "https://a.example"},
+				{ShortURL: "http://localhost:8080/b", OriginalURL: "https://b.example"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		userID string
+		query  string
+		want   want
+	}{
+		{
+			name: "unauthenticated",
+			want: want{
+				statusCode: http.StatusUnauthorized,
+				body:       `{"error":"Unauthorized"}`,
+			},
+		},
+		{
+			name:   "user without urls",
+			userID: "user-2",
+			want: want{
+				statusCode: http.StatusNoContent,
+			},
+		},
+		{
+			name:   "user urls",
+			userID: "user-1",
+			want: want{
+				statusCode: http.StatusOK,
+				body:       `[{"short_url":"http://localhost:8080/a","original_url":"https://a.example"},{"short_url":"http://localhost:8080/b","original_url":"https://b.example"}]`,
+			},
+		},
+		{
+			name:   "first page",
+			userID: "user-1",
+			query:  "?limit=1",
+			want: want{
+				statusCode: http.StatusOK,
+				body:       `[{"short_url":"http://localhost:8080/a","original_url":"https://a.example"}]`,
+				nextCursor: "next-page",
+			},
+		},
+		{
+			name:   "invalid limit",
+			userID: "user-1",
+			query:  "?limit=0",
+			want: want{
+				statusCode: http.StatusBadRequest,
+				body:       `{"error":"Invalid limit"}`,
+			},
+		},
+		{
+			name:   "invalid cursor",
+			userID: "user-1",
+			query:  "?cursor=garbage",
+			want: want{
+				statusCode: http.StatusBadRequest,
+				body:       `{"error":"Invalid cursor"}`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := setupGinRouter(NewHandler(mockService))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/user/urls"+test.query, nil)
+			if test.userID != "" {
+				req.AddCookie(&http.Cookie{Name: middleware.AuthCookieName, Value: testAuth.Sign(test.userID)})
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, test.want.statusCode, res.StatusCode)
+			assert.Equal(t, test.want.nextCursor, res.Header.Get("X-Next-Cursor"))
+
+			bodyBytes, _ := io.ReadAll(res.Body)
+			assert.Equal(t, test.want.body, strings.TrimSpace(string(bodyBytes)))
+		})
+	}
+}
+
+func TestDeleteUserURLs(t *testing.T) {
+	tests := []struct {
+		name        string
+		userID      string
+		contentType string
+		body        string
+		statusCode  int
+		deleted     []model.URLDeletion
+	}{
+		{
+			name:        "accepted",
+			userID:      "user-1",
+			contentType: "application/json",
+			body:        `["a","b"]`,
+			statusCode:  http.StatusAccepted,
+			deleted: []model.URLDeletion{
+				{UserID: "user-1", ID: "a"},
+				{UserID: "user-1", ID: "b"},
+			},
+		},
+		{
+			name:        "unauthenticated",
+			contentType: "application/json",
+			body:        `["a"]`,
+			statusCode:  http.StatusUnauthorized,
+		},
+		{
+			name:        "invalid json",
+			userID:      "user-1",
+			contentType: "application/json",
+			body:        `{"id":"a"}`,
+			statusCode:  http.StatusBadRequest,
+		},
+		{
+			name:        "too many ids",
+			userID:      "user-1",
+			contentType: "application/json",
+			body:        `["a"` + strings.Repeat(`,"a"`, service.MaxDeleteIDs) + `]`,
+			statusCode:  http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockService := &MockService{}
+			router := setupGinRouter(NewHandler(mockService))
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/user/urls", strings.NewReader(test.body))
+			req.Header.Set("Content-Type", test.contentType)
+			if test.userID != "" {
+				req.AddCookie(&http.Cookie{Name: middleware.AuthCookieName, Value: testAuth.Sign(test.userID)})
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, test.statusCode, res.StatusCode)
+			assert.Equal(t, test.deleted, mockService.deleted)
+		})
+	}
+}
+
+func TestGetURLStats(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		userID     string
+		statusCode int
+	}{
+		{name: "ok", target: "/api/urls/abc123/stats?from=2025-03-01T00:00:00Z&to=2025-03-02T00:00:00Z", userID: "user-1", statusCode: http.StatusOK},
+		{name: "unauthenticated", target: "/api/urls/abc123/stats", statusCode: http.StatusUnauthorized},
+		{name: "not found", target: "/api/urls/other/stats", userID: "user-1", statusCode: http.StatusNotFound},
+		{name: "invalid from", target: "/api/urls/abc123/stats?from=yesterday", userID: "user-1", statusCode: http.StatusBadRequest},
+		{name: "invalid top", target: "/api/urls/abc123/stats?top=1000", userID: "user-1", statusCode: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := setupGinRouter(NewHandler(&MockService{}))
+
+			req := httptest.NewRequest(http.MethodGet, test.target, nil)
+			if test.userID != "" {
+				req.AddCookie(&http.Cookie{Name: middleware.AuthCookieName, Value: testAuth.Sign(test.userID)})
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, test.statusCode, res.StatusCode)
+			if test.statusCode == http.StatusOK {
+				body, _ := io.ReadAll(res.Body)
+				assert.Contains(t, string(body), `"total_clicks":42`)
+				assert.Contains(t, string(body), `"from":"2025-03-01T00:00:00Z"`)
+			}
+		})
+	}
+}
+
+func TestGetServiceStats(t *testing.T) {
+	tests := []struct {
+		name       string
+		realIP     string
+		statusCode int
+		body       string
+	}{
+		{name: "trusted", realIP: "10.1.2.3", statusCode: http.StatusOK, body: `{"urls":3,"users":2}`},
+		{name: "untrusted", realIP: "192.0.2.1", statusCode: http.StatusForbidden, body: `{"error":"Forbidden"}`},
+		{name: "no header", statusCode: http.StatusForbidden, body: `{"error":"Forbidden"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := setupGinRouter(NewHandler(&MockService{}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
+			if test.realIP != "" {
+				req.Header.Set(middleware.RealIPHeader, test.realIP)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, test.statusCode, w.Code)
+			assert.Equal(t, test.body, strings.TrimSpace(w.Body.String()))
+		})
+	}
+}
+
+func TestShortenWithAlias(t *testing.T) {
+	type want struct {
+		statusCode int
+		body       string
+	}
+
+	tests := []struct {
+		name        string
+		target      string
+		contentType string
+		body        string
+		want        want
+	}{
+		{
+			name:        "text alias",
+			target:      "/?alias=spring-sale",
+			contentType: "text/plain",
+			body:        "https://example.com",
+			want: want{
+				statusCode: http.StatusCreated,
+				body:       "http://localhost:8080/spring-sale",
+			},
+		},
+		{
+			name:        "json alias",
+			target:      "/api/shorten",
+			contentType: "application/json",
+			body:        `{"url":"https://example.com","alias":"spring-sale"}`,
+			want: want{
+				statusCode: http.StatusCreated,
+				body:       `{"result":"http://localhost:8080/spring-sale"}`,
+			},
+		},
+		{
+			name:        "taken alias",
+			target:      "/api/shorten",
+			contentType: "application/json",
+			body:        `{"url":"https://example.com","alias":"taken"}`,
+			want: want{
+				statusCode: http.StatusConflict,
+				body:       `{"error":"Alias already taken"}`,
+			},
+		},
+		{
+			name:        "invalid alias",
+			target:      "/?alias=x",
+			contentType: "text/plain",
+			body:        "https://example.com",
+			want: want{
+				statusCode: http.StatusBadRequest,
+				body:       `{"error":"invalid alias: too short"}`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := setupGinRouter(NewHandler(&MockService{}))
+
+			req := httptest.NewRequest(http.MethodPost, test.target, strings.NewReader(test.body))
+			req.Header.Set("Content-Type", test.contentType)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, test.want.statusCode, res.StatusCode)
+
+			bodyBytes, _ := io.ReadAll(res.Body)
+			assert.Equal(t, test.want.body, strings.TrimSpace(string(bodyBytes)))
+		})
+	}
+}
+
+func TestShortenWithExpiry(t *testing.T) {
+	tests := []struct {
+		name        string
+		target      string
+		contentType string
+		body        string
+		statusCode  int
+	}{
+		{
+			name:        "text ttl",
+			target:      "/?ttl=72h",
+			contentType: "text/plain",
+			body:        "https://example.com",
+			statusCode:  http.StatusCreated,
+		},
+		{
+			name:        "text expires_at",
+			target:      "/?expires_at=2030-01-01T00:00:00Z",
+			contentType: "text/plain",
+			body:        "https://example.com",
+			statusCode:  http.StatusCreated,
+		},
+		{
+			name:        "json ttl",
+			target:      "/api/shorten",
+			contentType: "application/json",
+			body:        `{"url":"https://example.com","ttl":"24h"}`,
+			statusCode:  http.StatusCreated,
+		},
+		{
+			name:        "invalid ttl",
+			target:      "/?ttl=tomorrow",
+			contentType: "text/plain",
+			body:        "https://example.com",
+			statusCode:  http.StatusBadRequest,
+		},
+		{
+			name:        "invalid expires_at",
+			target:      "/?expires_at=2030-01-01",
+			contentType: "text/plain",
+			body:        "https://example.com",
+			statusCode:  http.StatusBadRequest,
+		},
+		{
+			name:        "json both set",
+			target:      "/api/shorten",
+			contentType: "application/json",
+			body:        `{"url":"https://example.com","ttl":"24h","expires_at":"2030-01-01T00:00:00Z"}`,
+			statusCode:  http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := setupGinRouter(NewHandler(&MockService{}))
+
+			req := httptest.NewRequest(http.MethodPost, test.target, strings.NewReader(test.body))
+			req.Header.Set("Content-Type", test.contentType)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, test.statusCode, res.StatusCode)
+		})
+	}
+}
+
+func TestPing(t *testing.T) {
+	tests := []struct {
+		name       string
+		pingErr    error
+		statusCode int
+	}{
+		{
+			name:       "database available",
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "database unavailable",
+			pingErr:    errors.New("connection refused"),
+			statusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := NewHandler(&MockService{pingErr: test.pingErr})
+			router := setupGinRouter(h)
+
+			req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, test.statusCode, res.StatusCode)
+		})
+	}
+}
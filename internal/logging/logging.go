@@ -0,0 +1,37 @@
+// Package logging передает логгер и идентификатор запроса через context, чтобы записи
+// всех слоев сервиса, сделанные в рамках одного запроса, можно было связать между собой.
+package logging
+
+import (
+	"context"
+	"go.uber.org/zap"
+)
+
+type loggerKey struct{}
+
+type requestIDKey struct{}
+
+// WithContext сохраняет logger в ctx.
+func WithContext(ctx context.Context, logger *zap.SugaredLogger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext возвращает логгер запроса. Вне запроса, например в фоновых задачах,
+// используется глобальный логгер zap, который приложение задает через zap.ReplaceGlobals.
+func FromContext(ctx context.Context) *zap.SugaredLogger {
+	if logger, ok := ctx.Value(loggerKey{}).(*zap.SugaredLogger); ok {
+		return logger
+	}
+	return zap.S()
+}
+
+// WithRequestID сохраняет идентификатор запроса в ctx.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID возвращает идентификатор запроса или пустую строку, если его нет.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
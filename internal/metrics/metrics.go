@@ -0,0 +1,206 @@
+// Package metrics собирает метрики сервиса в формате Prometheus.
+package metrics
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	namespace = "shortener"
+	// countTimeout ограничивает подсчет ссылок при сборе метрик
+	countTimeout = 5 * time.Second
+	// storedURLsTTL — как долго отдается уже подсчитанное число ссылок
+	storedURLsTTL = 30 * time.Second
+)
+
+// Результаты редиректа и сокращения — значения меток result.
+const (
+	RedirectHit     = "hit"
+	RedirectMiss    = "miss"
+	RedirectDeleted = "deleted"
+	RedirectExpired = "expired"
+	RedirectError   = "error"
+
+	ShortenSuccess  = "success"
+	ShortenConflict = "conflict"
+	ShortenInvalid  = "invalid"
+	ShortenError    = "error"
+)
+
+// Metrics хранит метрики в собственном реестре, чтобы тесты и несколько экземпляров
+// сервиса не конфликтовали в глобальном. Методы Observe* и Register* безопасны для nil:
+// без метрик сервис работает так же, только ничего не учитывает. Handler и Middleware
+// требуют настоящего экземпляра.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+	redirects    *prometheus.CounterVec
+	shortens     *prometheus.CounterVec
+	repoDuration *prometheus.HistogramVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route, method and status.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		redirects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "redirects_total",
+			Help:      "Short link lookups by result.",
+		}, []string{"result"}),
+		shortens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "shorten_total",
+			Help:      "Shorten requests by result.",
+		}, []string{"result"}),
+		repoDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "repository_operation_duration_seconds",
+			Help:      "Storage operation latency by operation and outcome.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"operation", "status"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.redirects,
+		m.shortens,
+		m.repoDuration,
+	)
+	return m
+}
+
+// Handler отдает метрики для сбора Prometheus.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware учитывает число и длительность запросов. В метку route попадает шаблон
+// маршрута, а не путь, чтобы короткие ID не раздували число временных рядов.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		m.httpRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+		m.httpDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+func (m *Metrics) ObserveRedirect(result string) {
+	if m == nil {
+		return
+	}
+	m.redirects.WithLabelValues(result).Inc()
+}
+
+func (m *Metrics) ObserveShorten(result string) {
+	if m == nil {
+		return
+	}
+	m.shortens.WithLabelValues(result).Inc()
+}
+
+func (m *Metrics) observeRepository(operation string, start time.Time, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	m.repoDuration.WithLabelValues(operation, status).Observe(time.Since(start).Seconds())
+}
+
+// RegisterStoredURLs добавляет gauge с числом хранимых ссылок. count вызывается при сборе метрик,
+// но не чаще раза в storedURLsTTL.
+func (m *Metrics) RegisterStoredURLs(count func(ctx context.Context) (int, error)) {
+	if m == nil {
+		return
+	}
+	m.registry.MustRegister(&storedURLsCollector{
+		desc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "stored_urls"),
+			"Number of stored (not deleted) short links.", nil, nil),
+		count: count,
+		now:   time.Now,
+	})
+}
+
+// storedURLsCollector кэширует число ссылок: подсчет по всей таблице не должен выполняться
+// при каждом сборе. Если подсчитать не удалось, значение не отдается совсем,
+// чтобы на графике не появился ложный ноль.
+type storedURLsCollector struct {
+	desc  *prometheus.Desc
+	count func(ctx context.Context) (int, error)
+	now   func() time.Time
+
+	mu        sync.Mutex
+	value     float64
+	updatedAt time.Time
+}
+
+func (c *storedURLsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *storedURLsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now := c.now(); c.updatedAt.IsZero() || now.Sub(c.updatedAt) >= storedURLsTTL {
+		ctx, cancel := context.WithTimeout(context.Background(), countTimeout)
+		defer cancel()
+		n, err := c.count(ctx)
+		if err != nil {
+			log.Printf("failed to count URLs for metrics: %v", err)
+			return
+		}
+		c.value, c.updatedAt = float64(n), now
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, c.value)
+}
+
+// RegisterClickBuffer добавляет метрики буфера аналитики: текущее заполнение и число отброшенных событий.
+func (m *Metrics) RegisterClickBuffer(depth func() int, dropped func() int64) {
+	if m == nil {
+		return
+	}
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "click_buffer_depth",
+			Help:      "Click events waiting in the analytics buffer.",
+		}, func() float64 { return float64(depth()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "clicks_dropped_total",
+			Help:      "Click events dropped because the analytics buffer was full.",
+		}, func() float64 { return float64(dropped()) }),
+	)
+}
@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"url-shortener/internal/model"
+	"url-shortener/internal/repository"
+)
+
+func TestMiddleware(t *testing.T) {
+	m := New()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(m.Middleware())
+	router.GET("/:id", func(c *gin.Context) {
+		c.Status(http.StatusTemporaryRedirect)
+	})
+	router.GET("/metrics", gin.WrapH(m.Handler()))
+
+	for _, path := range []string{"/abc", "/def", "/a/b"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.httpRequests.WithLabelValues("GET", "/:id", "307")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.httpRequests.WithLabelValues("GET", "unmatched", "404")))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(w.Result().Body)
+	assert.Contains(t, string(body), `shortener_http_request_duration_seconds_count{method="GET",route="/:id",status="307"} 2`)
+}
+
+func TestInstrumentRepository(t *testing.T) {
+	ctx := context.Background()
+	m := New()
+	repo := InstrumentRepository(repository.NewInMemoryURLRepository(), m)
+
+	_, pingable := repo.(repository.Pinger)
+	assert.False(t, pingable)
+
+	url := &model.URL{ID: "a", Original: "https://a.example"}
+	require.NoError(t, repo.Create(ctx, url))
+	require.Error(t, repo.Create(ctx, url))
+	_, err := repo.FindByID(ctx, "a")
+	require.NoError(t, err)
+
+	m.RegisterStoredURLs(repo.CountURLs)
+	m.RegisterClickBuffer(func() int { return 3 }, func() int64 { return 7 })
+
+	expected := `
+# HELP shortener_stored_urls Number of stored (not deleted) short links.
+# TYPE shortener_stored_urls gauge
+shortener_stored_urls 1
+# HELP shortener_click_buffer_depth Click events waiting in the analytics buffer.
+# TYPE shortener_click_buffer_depth gauge
+shortener_click_buffer_depth 3
+# HELP shortener_clicks_dropped_total Click events dropped because the analytics buffer was full.
+# TYPE shortener_clicks_dropped_total counter
+shortener_clicks_dropped_total 7
+`
+	require.NoError(t, testutil.GatherAndCompare(m.registry, strings.NewReader(expected),
+		"shortener_stored_urls", "shortener_click_buffer_depth", "shortener_clicks_dropped_total"))
+
+	// create, find_by_id и count_urls; конфликт — ожидаемый результат, а не ошибка хранилища
+	assert.Equal(t, 3, testutil.CollectAndCount(m.repoDuration))
+	formatted, err := testutil.CollectAndFormat(m.repoDuration, expfmt.TypeTextPlain, "shortener_repository_operation_duration_seconds")
+	require.NoError(t, err)
+	assert.Contains(t, string(formatted), `operation="create",status="ok"`)
+	assert.NotContains(t, string(formatted), `status="error"`)
+}
+
+func TestStoredURLs(t *testing.T) {
+	now := time.Now()
+	calls := 0
+	var countErr error
+	c := &storedURLsCollector{
+		desc: prometheus.NewDesc("stored_urls", "test", nil, nil),
+		count: func(ctx context.Context) (int, error) {
+			calls++
+			return 5, countErr
+		},
+		now: func() time.Time { return now },
+	}
+
+	// Подсчет не повторяется, пока значение не устарело
+	assert.Equal(t, 5.0, testutil.ToFloat64(c))
+	assert.Equal(t, 5.0, testutil.ToFloat64(c))
+	assert.Equal(t, 1, calls)
+
+	// Без успешного подсчета значение не отдается
+	now = now.Add(storedURLsTTL)
+	countErr = errors.New("database is down")
+	assert.Zero(t, testutil.CollectAndCount(c))
+	assert.Equal(t, 2, calls)
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *Metrics
+	m.ObserveRedirect(RedirectHit)
+	m.ObserveShorten(ShortenSuccess)
+	m.RegisterStoredURLs(nil)
+
+	repo := repository.NewInMemoryURLRepository()
+	assert.Same(t, repo, InstrumentRepository(repo, nil))
+}
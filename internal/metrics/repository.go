@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"time"
+	"url-shortener/internal/repository"
+)
+
+// operationLabels — значения метки operation; они уже используются в панелях, поэтому не меняются
+// вместе с именами методов.
+var operationLabels = map[string]string{
+	"Create":            "create",
+	"CreateBatch":       "create_batch",
+	"FindByID":          "find_by_id",
+	"FindByOriginalURL": "find_by_original_url",
+	"FindByUser":        "find_by_user",
+	"MarkDeleted":       "mark_deleted",
+	"DeleteExpired":     "delete_expired",
+	"CountURLs":         "count_urls",
+	"CountUsers":        "count_users",
+	"Ping":              "ping",
+}
+
+// InstrumentRepository измеряет длительность каждой операции хранилища. Без метрик repo возвращается как есть.
+func InstrumentRepository(repo repository.URLRepository, m *Metrics) repository.URLRepository {
+	if m == nil {
+		return repo
+	}
+	return repository.Observe(repo, func(ctx context.Context, op repository.Operation) (context.Context, func(error)) {
+		label, ok := operationLabels[op.Name]
+		if !ok {
+			label = strings.ToLower(op.Name)
+		}
+		start := time.Now()
+		return ctx, func(err error) { m.observeRepository(label, start, err) }
+	})
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	AuthCookieName = "auth_token"
+
+	userIDKey        = "userID"
+	authenticatedKey = "authenticated"
+
+	authCookieMaxAge = int(365 * 24 * time.Hour / time.Second)
+)
+
+var ErrInvalidToken = errors.New("invalid auth token")
+
+// Authenticator выдает и проверяет подписанные HMAC-SHA256 токены с идентификатором пользователя.
+// Токен имеет вид base64url(userID) + "." + base64url(hmac(userID)).
+type Authenticator struct {
+	secret []byte
+}
+
+func NewAuthenticator(secret []byte) *Authenticator {
+	return &Authenticator{secret: secret}
+}
+
+func (a *Authenticator) Sign(userID string) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(userID))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(a.mac(userID))
+}
+
+func (a *Authenticator) Verify(token string) (string, error) {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", ErrInvalidToken
+	}
+
+	userID, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || len(userID) == 0 {
+		return "", ErrInvalidToken
+	}
+	gotMAC, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+
+	if !hmac.Equal(gotMAC, a.mac(string(userID))) {
+		return "", ErrInvalidToken
+	}
+	return string(userID), nil
+}
+
+// Issue создает нового пользователя и подписанный токен для него.
+func (a *Authenticator) Issue() (userID, token string, err error) {
+	userID, err = newUserID()
+	if err != nil {
+		return "", "", err
+	}
+	return userID, a.Sign(userID), nil
+}
+
+func (a *Authenticator) mac(userID string) []byte {
+	h := hmac.New(sha256.New, a.secret)
+	h.Write([]byte(userID))
+	return h.Sum(nil)
+}
+
+// AuthMiddleware определяет пользователя по подписанной cookie. Если cookie нет или подпись
+// неверна, пользователю выдается новый идентификатор, но запрос не считается аутентифицированным.
+func AuthMiddleware(auth *Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token, err := c.Cookie(AuthCookieName); err == nil {
+			if userID, err := auth.Verify(token); err == nil {
+				c.Set(userIDKey, userID)
+				c.Set(authenticatedKey, true)
+				c.Next()
+				return
+			}
+		}
+
+		userID, token, err := auth.Issue()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			c.Abort()
+			return
+		}
+		c.SetSameSite(http.SameSiteLaxMode)
+		// По HTTPS cookie помечается Secure, чтобы не утечь через открытый HTTP
+		c.SetCookie(AuthCookieName, token, authCookieMaxAge, "/", "", c.Request.TLS != nil, true)
+		c.Set(userIDKey, userID)
+		c.Next()
+	}
+}
+
+// RequireAuth пропускает только запросы с действительной cookie, пришедшей от клиента.
+func RequireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !Authenticated(c) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// UserID возвращает идентификатор пользователя, установленный AuthMiddleware.
+func UserID(c *gin.Context) string {
+	return c.GetString(userIDKey)
+}
+
+// Authenticated сообщает, пришла ли от клиента действительная cookie.
+func Authenticated(c *gin.Context) bool {
+	return c.GetBool(authenticatedKey)
+}
+
+func newUserID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthenticator(t *testing.T) {
+	auth := NewAuthenticator([]byte("secret"))
+
+	token := auth.Sign("user-1")
+	userID, err := auth.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", userID)
+
+	other := NewAuthenticator([]byte("other-secret"))
+	_, err = other.Verify(token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	for _, bad := range []string{"", "no-dot", "dXNlci0y." + token[len("dXNlci0x."):], "!!!.???"} {
+		_, err := auth.Verify(bad)
+		assert.ErrorIs(t, err, ErrInvalidToken, bad)
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	auth := NewAuthenticator([]byte("secret"))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(AuthMiddleware(auth))
+	router.GET("/public", func(c *gin.Context) {
+		c.String(http.StatusOK, UserID(c))
+	})
+	router.GET("/private", RequireAuth(), func(c *gin.Context) {
+		c.String(http.StatusOK, UserID(c))
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		cookie     string
+		statusCode int
+		body       string
+		newCookie  bool
+	}{
+		{
+			name:       "public without cookie issues identity",
+			path:       "/public",
+			statusCode: http.StatusOK,
+			newCookie:  true,
+		},
+		{
+			name:       "public with valid cookie",
+			path:       "/public",
+			cookie:     auth.Sign("user-1"),
+			statusCode: http.StatusOK,
+			body:       "user-1",
+		},
+		{
+			name:       "public with tampered cookie issues new identity",
+			path:       "/public",
+			cookie:     auth.Sign("user-1") + "x",
+			statusCode: http.StatusOK,
+			newCookie:  true,
+		},
+		{
+			name:       "private with valid cookie",
+			path:       "/private",
+			cookie:     auth.Sign("user-1"),
+			statusCode: http.StatusOK,
+			body:       "user-1",
+		},
+		{
+			name:       "private with tampered cookie",
+			path:       "/private",
+			cookie:     NewAuthenticator([]byte("forged")).Sign("user-1"),
+			statusCode: http.StatusUnauthorized,
+			body:       `{"error":"Unauthorized"}`,
+			newCookie:  true,
+		},
+		{
+			name:       "private without cookie",
+			path:       "/private",
+			statusCode: http.StatusUnauthorized,
+			body:       `{"error":"Unauthorized"}`,
+			newCookie:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, test.path, nil)
+			if test.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: AuthCookieName, Value: test.cookie})
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, test.statusCode, res.StatusCode)
+			if test.body != "" {
+				assert.Equal(t, test.body, w.Body.String())
+			}
+
+			var issued *http.Cookie
+			for _, c := range res.Cookies() {
+				if c.Name == AuthCookieName {
+					issued = c
+				}
+			}
+			if !test.newCookie {
+				assert.Nil(t, issued)
+				return
+			}
+			require.NotNil(t, issued)
+			assert.False(t, issued.Secure)
+			userID, err := auth.Verify(issued.Value)
+			require.NoError(t, err)
+			if test.statusCode == http.StatusOK {
+				assert.Equal(t, userID, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareSecureCookie(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(AuthMiddleware(NewAuthenticator([]byte("secret"))))
+	router.GET("/", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.True(t, cookies[0].Secure)
+}
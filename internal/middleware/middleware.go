@@ -9,31 +9,43 @@ import (
 	"net/http"
 	"strings"
 	"time"
+	"url-shortener/internal/logging"
 )
 
+// HTTPLoggerMiddleware пишет одну запись журнала на запрос. Обработчикам и нижним слоям
+// логгер с идентификатором запроса доступен через logging.FromContext.
+// Должен подключаться после RequestIDMiddleware.
 func HTTPLoggerMiddleware(logger *zap.SugaredLogger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Начало запроса - засекаем время
 		start := time.Now()
 
+		requestID := logging.RequestID(c.Request.Context())
+		reqLogger := logger.With(zap.String("request_id", requestID))
+		c.Request = c.Request.WithContext(logging.WithContext(c.Request.Context(), reqLogger))
+
 		// Обрабатываем запрос
 		c.Next()
 
-		// Вычисляем затраченное время
-		duration := time.Since(start)
-
-		// Читаем размер содержимого ответа
+		// До первой записи размер ответа равен -1
 		size := c.Writer.Size()
+		if size < 0 {
+			size = 0
+		}
 
-		logger.Infow("HTTP Request",
-			zap.String("url", c.Request.RequestURI),
+		fields := []any{
 			zap.String("method", c.Request.Method),
-			zap.Duration("duration", duration),
-		)
-		logger.Infow("HTTP Response",
+			zap.String("path", c.Request.URL.Path),
 			zap.Int("status", c.Writer.Status()),
 			zap.Int("size", size),
-		)
+			zap.Duration("duration", time.Since(start)),
+			zap.String("client_ip", c.ClientIP()),
+			zap.String("user_id", UserID(c)),
+		}
+		if len(c.Errors) > 0 {
+			fields = append(fields, zap.String("errors", c.Errors.String()))
+		}
+		reqLogger.Infow("HTTP request", fields...)
 	}
 }
 
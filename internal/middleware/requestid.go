@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"url-shortener/internal/logging"
+)
+
+// RequestIDHeader — заголовок с идентификатором запроса во входящем запросе и в ответе.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength ограничивает идентификатор, пришедший от клиента, чтобы он не раздувал журнал.
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware принимает идентификатор запроса из X-Request-ID или создает новый,
+// кладет его в контекст запроса и возвращает клиенту в том же заголовке.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := RequestID(c.GetHeader(RequestIDHeader))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			c.Abort()
+			return
+		}
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// RequestID возвращает идентификатор, присланный клиентом, если он допустим, или создает новый.
+func RequestID(candidate string) (string, error) {
+	if validRequestID(candidate) {
+		return candidate, nil
+	}
+	return newRequestID()
+}
+
+// validRequestID допускает только печатные ASCII-символы без пробелов, чтобы клиент
+// не мог подделать записи журнала переводами строк.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
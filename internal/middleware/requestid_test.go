@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"url-shortener/internal/logging"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestIDMiddleware())
+	router.Use(HTTPLoggerMiddleware(zap.New(core).Sugar()))
+	router.GET("/:id", func(c *gin.Context) {
+		logging.FromContext(c.Request.Context()).Infow("handler")
+		c.String(http.StatusOK, "ok")
+	})
+
+	tests := []struct {
+		name     string
+		header   string
+		accepted bool
+	}{
+		{name: "accepted from client", header: "req-42", accepted: true},
+		{name: "generated when missing"},
+		{name: "generated when unsafe", header: "bad id\nforged"},
+		{name: "generated when too long", header: strings.Repeat("a", maxRequestIDLength+1)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logs.TakeAll()
+
+			req := httptest.NewRequest(http.MethodGet, "/abc?x=1", nil)
+			if test.header != "" {
+				req.Header.Set(RequestIDHeader, test.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			id := w.Header().Get(RequestIDHeader)
+			if test.accepted {
+				assert.Equal(t, test.header, id)
+			} else {
+				assert.Len(t, id, 32)
+			}
+
+			// Запись обработчика и запись журнала доступа связаны одним идентификатором
+			entries := logs.TakeAll()
+			require.Len(t, entries, 2)
+			for _, entry := range entries {
+				assert.Equal(t, id, entry.ContextMap()["request_id"])
+			}
+
+			access := entries[1].ContextMap()
+			assert.Equal(t, "HTTP request", entries[1].Message)
+			assert.Equal(t, "GET", access["method"])
+			assert.Equal(t, "/abc", access["path"])
+			assert.EqualValues(t, http.StatusOK, access["status"])
+			assert.EqualValues(t, 2, access["size"])
+			assert.Equal(t, "192.0.2.1", access["client_ip"])
+			assert.Contains(t, access, "duration")
+			assert.Contains(t, access, "user_id")
+		})
+	}
+}
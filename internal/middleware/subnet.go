@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// RealIPHeader — заголовок, в котором обратный прокси передает адрес клиента.
+const RealIPHeader = "X-Real-IP"
+
+// ParseTrustedSubnet разбирает подсеть в нотации CIDR. Пустая строка означает, что доверенной подсети нет.
+func ParseTrustedSubnet(cidr string) (*net.IPNet, error) {
+	if cidr == "" {
+		return nil, nil
+	}
+	_, subnet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted subnet %q: %w", cidr, err)
+	}
+	return subnet, nil
+}
+
+// TrustedSubnet пропускает только запросы, у которых адрес из X-Real-IP входит в subnet.
+// Если subnet не задана, доступ запрещен всем.
+func TrustedSubnet(subnet *net.IPNet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := net.ParseIP(strings.TrimSpace(c.GetHeader(RealIPHeader)))
+		if subnet == nil || ip == nil || !subnet.Contains(ip) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrustedSubnet(t *testing.T) {
+	subnet, err := ParseTrustedSubnet("192.168.1.0/24")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		subnet     string
+		realIP     string
+		statusCode int
+	}{
+		{name: "inside subnet", subnet: "192.168.1.0/24", realIP: "192.168.1.10", statusCode: http.StatusOK},
+		{name: "outside subnet", subnet: "192.168.1.0/24", realIP: "10.0.0.1", statusCode: http.StatusForbidden},
+		{name: "missing header", subnet: "192.168.1.0/24", statusCode: http.StatusForbidden},
+		{name: "invalid header", subnet: "192.168.1.0/24", realIP: "not-an-ip", statusCode: http.StatusForbidden},
+		{name: "subnet not configured", realIP: "192.168.1.10", statusCode: http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var trusted = subnet
+			if test.subnet == "" {
+				trusted = nil
+			}
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/internal", TrustedSubnet(trusted), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/internal", nil)
+			if test.realIP != "" {
+				req.Header.Set(RealIPHeader, test.realIP)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, test.statusCode, w.Code)
+		})
+	}
+
+	_, err = ParseTrustedSubnet("192.168.1.0")
+	assert.Error(t, err)
+}
@@ -1,15 +1,91 @@
 package model
 
+import "time"
+
 type URL struct {
-	ID       string `json:"id"`
-	Original string `json:"original"`
-	Short    string `json:"short"`
+	ID        string     `json:"id"`
+	Original  string     `json:"original"`
+	Short     string     `json:"short"`
+	UserID    string     `json:"user_id,omitempty"`
+	Deleted   bool       `json:"is_deleted,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Expired сообщает, истек ли срок действия ссылки к моменту now.
+func (u *URL) Expired(now time.Time) bool {
+	return u.ExpiresAt != nil && !now.Before(*u.ExpiresAt)
 }
 
 type ShortenRequest struct {
-	URL string `json:"url" binding:"required"`
+	URL   string `json:"url" binding:"required"`
+	Alias string `json:"alias,omitempty"`
+	// ExpiresAt и TTL задают срок действия ссылки; указывать можно только одно из них
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	TTL       string     `json:"ttl,omitempty"`
 }
 
 type ShortenResponse struct {
 	Result string `json:"result"`
 }
+
+type BatchRequestItem struct {
+	CorrelationID string `json:"correlation_id"`
+	OriginalURL   string `json:"original_url"`
+}
+
+type BatchResponseItem struct {
+	CorrelationID string `json:"correlation_id"`
+	ShortURL      string `json:"short_url"`
+}
+
+type UserURL struct {
+	ShortURL    string `json:"short_url"`
+	OriginalURL string `json:"original_url"`
+}
+
+// URLDeletion — запрос на удаление ссылки ID от имени пользователя UserID.
+type URLDeletion struct {
+	UserID string
+	ID     string
+}
+
+// Click — переход по короткой ссылке. IP клиента хранится только в виде хеша.
+type Click struct {
+	URLID     string    `json:"url_id"`
+	Time      time.Time `json:"time"`
+	Referrer  string    `json:"referrer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	IPHash    string    `json:"ip_hash,omitempty"`
+}
+
+// ClickStats — статистика переходов по ссылке за интервал [From, To).
+type ClickStats struct {
+	URLID          string       `json:"url_id"`
+	From           time.Time    `json:"from"`
+	To             time.Time    `json:"to"`
+	TotalClicks    int64        `json:"total_clicks"`
+	UniqueVisitors int64        `json:"unique_visitors"`
+	Daily          []StatsPoint `json:"daily"`
+	Hourly         []StatsPoint `json:"hourly"`
+	TopReferrers   []StatsEntry `json:"top_referrers"`
+	TopUserAgents  []StatsEntry `json:"top_user_agents"`
+}
+
+// StatsPoint — число переходов в интервале, начинающемся в Time.
+type StatsPoint struct {
+	Time   time.Time `json:"time"`
+	Clicks int64     `json:"clicks"`
+}
+
+// StatsEntry — число переходов с одним значением признака (источник, user agent).
+type StatsEntry struct {
+	Value  string `json:"value"`
+	Clicks int64  `json:"clicks"`
+}
+
+// ServiceStats — сводка по сервису для внутреннего мониторинга.
+type ServiceStats struct {
+	URLs  int `json:"urls"`
+	Users int `json:"users"`
+}
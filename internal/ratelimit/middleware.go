@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"github.com/gin-gonic/gin"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+	"url-shortener/internal/middleware"
+)
+
+const (
+	headerLimit      = "X-RateLimit-Limit"
+	headerRemaining  = "X-RateLimit-Remaining"
+	headerReset      = "X-RateLimit-Reset"
+	headerRetryAfter = "Retry-After"
+)
+
+// Middleware ограничивает запросы группы name лимитом limit. Каждый запрос списывается с корзины
+// IP клиента, а запрос аутентифицированного пользователя — еще и с корзины пользователя: иначе
+// клиент, выбрасывающий cookie, получал бы с каждой новой cookie полную корзину. Адрес берется
+// из c.ClientIP(), поэтому заголовкам X-Forwarded-For и X-Real-IP верят только от доверенных прокси роутера.
+func Middleware(store Store, name string, limit Limit) gin.HandlerFunc {
+	if !limit.Enabled() {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		userID := ""
+		if middleware.Authenticated(c) {
+			userID = middleware.UserID(c)
+		}
+		res, err := TakeAll(c.Request.Context(), store, limit, ClientKeys(name, c.ClientIP(), userID)...)
+		if err != nil {
+			// Недоступность хранилища лимитов не должна останавливать сервис
+			_ = c.Error(err)
+			c.Next()
+			return
+		}
+
+		c.Header(headerLimit, strconv.Itoa(limit.Burst))
+		c.Header(headerRemaining, strconv.Itoa(res.Remaining))
+		c.Header(headerReset, ceilSeconds(res.Reset))
+		if !res.Allowed {
+			c.Header(headerRetryAfter, ceilSeconds(res.RetryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
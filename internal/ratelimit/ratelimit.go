@@ -0,0 +1,180 @@
+// Package ratelimit ограничивает частоту запросов клиентов алгоритмом token bucket.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit — скорость пополнения корзины и ее емкость. Нулевой Limit ничего не ограничивает.
+type Limit struct {
+	// Rate — токенов в секунду
+	Rate float64
+	// Burst — сколько запросов подряд можно сделать с полной корзиной
+	Burst int
+}
+
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// ParseLimit разбирает скорость вида "10/s", "60/m" или "1000/h". Пустая строка и "0" отключают ограничение.
+func ParseLimit(rate string, burst int) (Limit, error) {
+	rate = strings.TrimSpace(rate)
+	if rate == "" || rate == "0" {
+		return Limit{}, nil
+	}
+
+	count, unit, ok := strings.Cut(rate, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: expected format like 60/m", rate)
+	}
+	n, err := strconv.ParseFloat(count, 64)
+	if err != nil || n <= 0 || math.IsInf(n, 0) {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: count must be a positive number", rate)
+	}
+	var period time.Duration
+	switch unit {
+	case "s":
+		period = time.Second
+	case "m":
+		period = time.Minute
+	case "h":
+		period = time.Hour
+	default:
+		return Limit{}, fmt.Errorf("invalid rate limit %q: unit must be s, m or h", rate)
+	}
+	if burst <= 0 {
+		return Limit{}, fmt.Errorf("rate limit burst must be positive")
+	}
+	return Limit{Rate: n / period.Seconds(), Burst: burst}, nil
+}
+
+// Result — итог попытки взять токен.
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter — через сколько появится токен, если запрос отклонен
+	RetryAfter time.Duration
+	// Reset — через сколько корзина заполнится полностью
+	Reset time.Duration
+}
+
+// Store хранит корзины клиентов. MemoryStore подходит для одного экземпляра сервиса;
+// при нескольких экземплярах нужна реализация поверх общего хранилища, например Redis.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// ClientKeys возвращает ключи корзин клиента в группе name: адрес всегда, пользователя — если он известен.
+func ClientKeys(name, addr, userID string) []string {
+	keys := []string{name + ":ip:" + addr}
+	if userID != "" {
+		keys = append(keys, name+":user:"+userID)
+	}
+	return keys
+}
+
+// TakeAll берет токен из корзины каждого ключа и разрешает запрос, только если разрешили все.
+// После первого отказа остальные корзины не тратятся. Remaining — наименьший остаток,
+// RetryAfter и Reset — наибольшие.
+func TakeAll(ctx context.Context, store Store, limit Limit, keys ...string) (Result, error) {
+	combined := Result{Allowed: true, Remaining: limit.Burst}
+	for _, key := range keys {
+		res, err := store.Take(ctx, key, limit)
+		if err != nil {
+			return Result{}, err
+		}
+		combined.Remaining = min(combined.Remaining, res.Remaining)
+		combined.Reset = max(combined.Reset, res.Reset)
+		if !res.Allowed {
+			combined.Allowed = false
+			combined.RetryAfter = max(combined.RetryAfter, res.RetryAfter)
+			break
+		}
+	}
+	return combined, nil
+}
+
+// sweepInterval — как часто MemoryStore удаляет корзины, которые уже успели заполниться.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	// limit — лимит последнего запроса, по нему корзина проверяется при очистке
+	limit Limit
+}
+
+// MemoryStore держит корзины в памяти процесса.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		s.buckets[key] = b
+	}
+	b.limit = limit
+	b.tokens = b.refill(now)
+	b.last = now
+
+	res := Result{}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = seconds((float64(limit.Burst) - b.tokens) / limit.Rate)
+	return res, nil
+}
+
+// sweep удаляет заполнившиеся корзины: новая корзина для того же ключа ничем от них не отличается.
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if b.refill(now) >= float64(b.limit.Burst) {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+func (b *bucket) refill(now time.Time) float64 {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,165 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url-shortener/internal/middleware"
+)
+
+func TestParseLimit(t *testing.T) {
+	limit, err := ParseLimit("60/m", 10)
+	require.NoError(t, err)
+	assert.Equal(t, Limit{Rate: 1, Burst: 10}, limit)
+
+	limit, err = ParseLimit("", 0)
+	require.NoError(t, err)
+	assert.False(t, limit.Enabled())
+
+	for _, rate := range []string{"60", "-1/s", "abc/s", "10/d"} {
+		_, err := ParseLimit(rate, 10)
+		assert.Error(t, err, rate)
+	}
+	_, err = ParseLimit("10/s", 0)
+	assert.Error(t, err)
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	limit := Limit{Rate: 1, Burst: 2}
+
+	res, err := store.Take(ctx, "a", limit)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Allowed: true, Remaining: 1, Reset: time.Second}, res)
+
+	res, _ = store.Take(ctx, "a", limit)
+	assert.True(t, res.Allowed)
+	res, _ = store.Take(ctx, "a", limit)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, time.Second, res.RetryAfter)
+
+	// Другой ключ расходует собственную корзину
+	res, _ = store.Take(ctx, "b", limit)
+	assert.True(t, res.Allowed)
+
+	now = now.Add(500 * time.Millisecond)
+	res, _ = store.Take(ctx, "a", limit)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+
+	now = now.Add(500 * time.Millisecond)
+	res, _ = store.Take(ctx, "a", limit)
+	assert.True(t, res.Allowed)
+
+	// Заполнившиеся корзины удаляются при очистке, пустые остаются
+	now = now.Add(sweepInterval)
+	store.buckets["c"] = &bucket{last: now, limit: limit}
+	_, _ = store.Take(ctx, "d", limit)
+	assert.NotContains(t, store.buckets, "a")
+	assert.Contains(t, store.buckets, "c")
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.AuthMiddleware(middleware.NewAuthenticator([]byte("secret"))))
+	router.GET("/", Middleware(NewMemoryStore(), "test", Limit{Rate: 0.1, Burst: 1}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(remoteAddr string, cookie *http.Cookie) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	res := request("192.0.2.1:1234", nil)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "1", res.Header.Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", res.Header.Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "10", res.Header.Get("X-RateLimit-Reset"))
+
+	res = request("192.0.2.1:1234", nil)
+	assert.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+	assert.Equal(t, "10", res.Header.Get("Retry-After"))
+
+	// Другой адрес учитывается отдельно
+	assert.Equal(t, http.StatusOK, request("192.0.2.2:1234", nil).StatusCode)
+
+	// Запрос пользователя списывается и с его корзины, и с корзины адреса
+	auth := middleware.NewAuthenticator([]byte("secret"))
+	cookie := &http.Cookie{Name: middleware.AuthCookieName, Value: auth.Sign("user-1")}
+	assert.Equal(t, http.StatusOK, request("192.0.2.3:1234", cookie).StatusCode)
+	assert.Equal(t, http.StatusTooManyRequests, request("192.0.2.4:1234", cookie).StatusCode)
+	other := &http.Cookie{Name: middleware.AuthCookieName, Value: auth.Sign("user-2")}
+	assert.Equal(t, http.StatusTooManyRequests, request("192.0.2.1:1234", other).StatusCode)
+}
+
+func TestMiddlewareCookieRotation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.AuthMiddleware(middleware.NewAuthenticator([]byte("secret"))))
+	router.GET("/", Middleware(NewMemoryStore(), "test", Limit{Rate: 0.001, Burst: 3}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	// Скрипт каждый раз выбрасывает cookie и пользуется только что выданной
+	allowed := 0
+	var cookie *http.Cookie
+	for i := 0; i < 20; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		if cookie != nil {
+			req.AddCookie(cookie)
+			cookie = nil
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code == http.StatusOK {
+			allowed++
+		}
+		for _, c := range w.Result().Cookies() {
+			if c.Name == middleware.AuthCookieName {
+				cookie = c
+			}
+		}
+	}
+	assert.Equal(t, 3, allowed)
+}
+
+func TestTakeAll(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	limit := Limit{Rate: 0.001, Burst: 2}
+
+	res, err := TakeAll(ctx, store, limit, "ip", "user")
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 1, res.Remaining)
+
+	res, err = TakeAll(ctx, store, limit, "ip", "other")
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+
+	// После отказа по адресу корзина пользователя не тратится
+	res, err = TakeAll(ctx, store, limit, "ip", "user")
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	res, err = TakeAll(ctx, store, limit, "user")
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
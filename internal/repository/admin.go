@@ -0,0 +1,372 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"url-shortener/internal/model"
+)
+
+// Admin — операции обслуживания хранилища для утилиты shortenctl. Сервису они не нужны,
+// поэтому не входят в URLRepository и проверяются приведением типа, как Pinger.
+type Admin interface {
+	// Scan передает fn все записи, включая удаленные, в порядке создания, начиная после курсора after.
+	// Вместе с записью fn получает ее курсор, с которого обход можно продолжить.
+	Scan(ctx context.Context, after string, fn func(url *model.URL, cursor string) error) error
+	// Remove окончательно удаляет записи независимо от владельца и возвращает их количество.
+	Remove(ctx context.Context, ids []string) (int, error)
+	// Verify проверяет целостность хранилища и возвращает найденные нарушения.
+	Verify(ctx context.Context) ([]Problem, error)
+}
+
+// Виды нарушений целостности.
+const (
+	// ProblemDuplicateOriginal — один оригинальный URL сокращен несколько раз
+	ProblemDuplicateOriginal = "duplicate_original"
+	// ProblemDanglingIndex — запись индекса ссылается на отсутствующую или другую ссылку
+	ProblemDanglingIndex = "dangling_index"
+	// ProblemMissingIndex — ссылка отсутствует в индексе
+	ProblemMissingIndex = "missing_index"
+	// ProblemOrphanClicks — переходы по ссылке, которой нет в хранилище
+	ProblemOrphanClicks = "orphan_clicks"
+)
+
+type Problem struct {
+	Kind   string `json:"kind"`
+	ID     string `json:"id"`
+	Detail string `json:"detail"`
+}
+
+// scan копирует записи после курсора, чтобы fn вызывался без блокировки. Вызывается под блокировкой.
+func (ix *urlIndex) scan(after string) ([]*model.URL, []string, error) {
+	seq, err := parseCursor(after)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		urls    []*model.URL
+		cursors []string
+	)
+	for _, url := range ix.ordered() {
+		if ix.seq[url.ID] <= seq {
+			continue
+		}
+		u := *url
+		urls = append(urls, &u)
+		cursors = append(cursors, formatCursor(ix.seq[url.ID]))
+	}
+	return urls, cursors, nil
+}
+
+func scanCopied(ctx context.Context, urls []*model.URL, cursors []string, fn func(*model.URL, string) error) error {
+	for i, url := range urls {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(url, cursors[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// existing возвращает ID из списка, которые есть в индексе.
+func (ix *urlIndex) existing(ids []string) []string {
+	found := make([]string, 0, len(ids))
+	seen := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		if _, ok := ix.data[id]; ok {
+			found = append(found, id)
+		}
+	}
+	return found
+}
+
+// survivors находит записи, которые после удаления ids останутся без индекса оригинального URL.
+// Так бывает только с дубликатами: их нужно вставить заново, чтобы индекс указал на оставшуюся запись.
+func (ix *urlIndex) survivors(ids []string) []*model.URL {
+	removed := make(map[string]struct{}, len(ids))
+	orphaned := make(map[string]struct{})
+	for _, id := range ids {
+		removed[id] = struct{}{}
+		if url := ix.data[id]; url != nil && ix.originalURLs[url.Original] == id {
+			orphaned[url.Original] = struct{}{}
+		}
+	}
+	if len(orphaned) == 0 {
+		return nil
+	}
+
+	// Как и при загрузке файла, индекс получает последняя из оставшихся записей
+	latest := make(map[string]*model.URL)
+	for id, url := range ix.data {
+		if _, ok := removed[id]; ok {
+			continue
+		}
+		if _, ok := orphaned[url.Original]; !ok {
+			continue
+		}
+		if cur := latest[url.Original]; cur == nil || ix.seq[id] > ix.seq[cur.ID] {
+			latest[url.Original] = url
+		}
+	}
+	urls := make([]*model.URL, 0, len(latest))
+	for _, url := range latest {
+		u := *url
+		urls = append(urls, &u)
+	}
+	sort.Slice(urls, func(i, j int) bool {
+		return ix.seq[urls[i].ID] < ix.seq[urls[j].ID]
+	})
+	return urls
+}
+
+// verify сверяет вспомогательные индексы с записями. Вызывается под блокировкой.
+func (ix *urlIndex) verify() []Problem {
+	var problems []Problem
+
+	byOriginal := make(map[string][]string)
+	active := 0
+	for id, url := range ix.data {
+		if url.ID != id {
+			problems = append(problems, Problem{Kind: ProblemDanglingIndex, ID: id,
+				Detail: fmt.Sprintf("record is stored under ID %s", url.ID)})
+		}
+		if _, ok := ix.seq[id]; !ok {
+			problems = append(problems, Problem{Kind: ProblemMissingIndex, ID: id, Detail: "no insertion order"})
+		}
+		if !url.Deleted && ix.originalURLs[url.Original] == "" {
+			problems = append(problems, Problem{Kind: ProblemMissingIndex, ID: id, Detail: "original URL is not indexed"})
+		}
+		if url.UserID != "" && !contains(ix.userURLs[url.UserID], id) {
+			problems = append(problems, Problem{Kind: ProblemMissingIndex, ID: id,
+				Detail: fmt.Sprintf("not listed for user %s", url.UserID)})
+		}
+		if !url.Deleted {
+			active++
+			byOriginal[url.Original] = append(byOriginal[url.Original], id)
+		}
+	}
+
+	for original, ids := range byOriginal {
+		if len(ids) > 1 {
+			sort.Strings(ids)
+			for _, id := range ids {
+				problems = append(problems, Problem{Kind: ProblemDuplicateOriginal, ID: id,
+					Detail: fmt.Sprintf("%s is also shortened as %v", original, ids)})
+			}
+		}
+	}
+	for original, id := range ix.originalURLs {
+		if url := ix.data[id]; url == nil || url.Original != original {
+			problems = append(problems, Problem{Kind: ProblemDanglingIndex, ID: id,
+				Detail: fmt.Sprintf("original URL %s points to missing record", original)})
+		}
+	}
+	for id := range ix.seq {
+		if _, ok := ix.data[id]; !ok {
+			problems = append(problems, Problem{Kind: ProblemDanglingIndex, ID: id, Detail: "insertion order of missing record"})
+		}
+	}
+	for userID, ids := range ix.userURLs {
+		for _, id := range ids {
+			if url := ix.data[id]; url == nil || url.UserID != userID {
+				problems = append(problems, Problem{Kind: ProblemDanglingIndex, ID: id,
+					Detail: fmt.Sprintf("listed for user %s but not owned by them", userID)})
+			}
+		}
+	}
+	if active != ix.active {
+		problems = append(problems, Problem{Kind: ProblemDanglingIndex,
+			Detail: fmt.Sprintf("active counter is %d, actual %d", ix.active, active)})
+	}
+
+	sort.Slice(problems, func(i, j int) bool {
+		if problems[i].Kind != problems[j].Kind {
+			return problems[i].Kind < problems[j].Kind
+		}
+		return problems[i].ID < problems[j].ID
+	})
+	return problems
+}
+
+func contains(ids []string, id string) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *InMemoryURLRepository) Scan(ctx context.Context, after string, fn func(*model.URL, string) error) error {
+	r.mu.RLock()
+	urls, cursors, err := r.scan(after)
+	r.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return scanCopied(ctx, urls, cursors, fn)
+}
+
+func (r *InMemoryURLRepository) Remove(ctx context.Context, ids []string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	found := r.existing(ids)
+	survivors := r.survivors(found)
+	for _, id := range found {
+		r.remove(id)
+	}
+	for _, url := range survivors {
+		r.put(url)
+	}
+	return len(found), nil
+}
+
+func (r *InMemoryURLRepository) Verify(ctx context.Context) ([]Problem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.verify(), nil
+}
+
+func (r *FileURLRepository) Scan(ctx context.Context, after string, fn func(*model.URL, string) error) error {
+	r.mu.RLock()
+	urls, cursors, err := r.scan(after)
+	r.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return scanCopied(ctx, urls, cursors, fn)
+}
+
+func (r *FileURLRepository) Remove(ctx context.Context, ids []string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	found := r.existing(ids)
+	if len(found) == 0 {
+		return 0, nil
+	}
+	survivors := r.survivors(found)
+	if err := r.appendRecord(journalRecord{Op: journalOpDelete, IDs: found}); err != nil {
+		return 0, fmt.Errorf("failed to save removal to file: %w", err)
+	}
+	for _, id := range found {
+		r.remove(id)
+	}
+	if len(survivors) == 0 {
+		return len(found), nil
+	}
+	if err := r.appendRecord(journalRecord{Op: journalOpPut, URLs: survivors}); err != nil {
+		return len(found), fmt.Errorf("failed to save reindexed URLs to file: %w", err)
+	}
+	for _, url := range survivors {
+		r.put(url)
+	}
+	return len(found), nil
+}
+
+// Verify проверяет индекс, построенный при загрузке. Дубликаты оригинальных URL появляются,
+// если файл правили вручную: при загрузке побеждает последняя запись.
+func (r *FileURLRepository) Verify(ctx context.Context) ([]Problem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.verify(), nil
+}
+
+func (r *PostgresURLRepository) Scan(ctx context.Context, after string, fn func(*model.URL, string) error) error {
+	seq, err := parseCursor(after)
+	if err != nil {
+		return err
+	}
+
+	rows, err := r.db.QueryContext(ctx, "SELECT "+urlColumns+", seq FROM urls WHERE seq > $1 ORDER BY seq", seq)
+	if err != nil {
+		return fmt.Errorf("failed to scan URLs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var url model.URL
+		if err := rows.Scan(&url.ID, &url.Original, &url.Short, &url.UserID, &url.Deleted, &url.CreatedAt, &url.ExpiresAt, &seq); err != nil {
+			return fmt.Errorf("failed to scan URL: %w", err)
+		}
+		if err := fn(&url, formatCursor(seq)); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to scan URLs: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresURLRepository) Remove(ctx context.Context, ids []string) (int, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM urls WHERE id = ANY($1::text[])`, ids)
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove URLs: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove URLs: %w", err)
+	}
+	return int(n), nil
+}
+
+// Verify ищет действующие дубликаты на случай, если уникальный индекс по original_url был удален,
+// и переходы по ссылкам, которых уже нет: они остаются после удаления истекших ссылок.
+func (r *PostgresURLRepository) Verify(ctx context.Context) ([]Problem, error) {
+	var problems []Problem
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT u.id, u.original_url, d.ids
+		FROM urls u
+		JOIN (SELECT original_url, string_agg(id, ', ' ORDER BY id) AS ids
+		      FROM urls WHERE NOT is_deleted GROUP BY original_url HAVING count(*) > 1) d USING (original_url)
+		WHERE NOT u.is_deleted
+		ORDER BY u.id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find duplicate URLs: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, original, ids string
+		if err := rows.Scan(&id, &original, &ids); err != nil {
+			return nil, fmt.Errorf("failed to find duplicate URLs: %w", err)
+		}
+		problems = append(problems, Problem{Kind: ProblemDuplicateOriginal, ID: id,
+			Detail: fmt.Sprintf("%s is also shortened as [%s]", original, ids)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to find duplicate URLs: %w", err)
+	}
+
+	orphans, err := r.db.QueryContext(ctx, `
+		SELECT c.url_id, count(*)
+		FROM clicks c LEFT JOIN urls u ON u.id = c.url_id
+		WHERE u.id IS NULL
+		GROUP BY c.url_id ORDER BY c.url_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find orphan clicks: %w", err)
+	}
+	defer orphans.Close()
+	for orphans.Next() {
+		var (
+			id    string
+			count int
+		)
+		if err := orphans.Scan(&id, &count); err != nil {
+			return nil, fmt.Errorf("failed to find orphan clicks: %w", err)
+		}
+		problems = append(problems, Problem{Kind: ProblemOrphanClicks, ID: id,
+			Detail: fmt.Sprintf("%d clicks reference a missing URL", count)})
+	}
+	if err := orphans.Err(); err != nil {
+		return nil, fmt.Errorf("failed to find orphan clicks: %w", err)
+	}
+	return problems, nil
+}
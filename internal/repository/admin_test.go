@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"url-shortener/internal/model"
+)
+
+func TestAdminScanRemoveVerify(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range newTestRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			admin, ok := repo.(Admin)
+			require.True(t, ok)
+
+			for _, id := range []string{"a", "b", "c"} {
+				require.NoError(t, repo.Create(ctx, &model.URL{ID: id, Original: "https://" + id + ".example", Short: "http://s/" + id, UserID: "user-1"}))
+			}
+			require.NoError(t, repo.MarkDeleted(ctx, []model.URLDeletion{{UserID: "user-1", ID: "b"}}))
+
+			var (
+				ids     []string
+				cursors []string
+			)
+			require.NoError(t, admin.Scan(ctx, "", func(url *model.URL, cursor string) error {
+				ids = append(ids, url.ID)
+				cursors = append(cursors, cursor)
+				return nil
+			}))
+			assert.Equal(t, []string{"a", "b", "c"}, ids)
+
+			// Обход продолжается после курсора записи
+			ids = nil
+			require.NoError(t, admin.Scan(ctx, cursors[0], func(url *model.URL, cursor string) error {
+				ids = append(ids, url.ID)
+				return nil
+			}))
+			assert.Equal(t, []string{"b", "c"}, ids)
+			assert.ErrorIs(t, admin.Scan(ctx, "bad", func(*model.URL, string) error { return nil }), ErrInvalidCursor)
+
+			problems, err := admin.Verify(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, problems)
+
+			n, err := admin.Remove(ctx, []string{"a", "b", "missing", "a"})
+			require.NoError(t, err)
+			assert.Equal(t, 2, n)
+			u, err := repo.FindByID(ctx, "a")
+			require.NoError(t, err)
+			assert.Nil(t, u)
+			count, err := repo.CountURLs(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, 1, count)
+
+			problems, err = admin.Verify(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, problems)
+		})
+	}
+}
+
+func TestFileRepositoryVerifyHandEdited(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.json")
+
+	// Две записи с одним оригинальным URL, как после ручной правки файла
+	journal := `{"op":"put","urls":[{"id":"a","original":"https://same.example","short":"http://s/a","created_at":"2024-01-01T00:00:00Z"}]}
+{"op":"put","urls":[{"id":"b","original":"https://same.example","short":"http://s/b","created_at":"2024-01-01T00:00:00Z"}]}
+`
+	require.NoError(t, os.WriteFile(path, []byte(journal), 0644))
+
+	repo, err := NewFileURLRepository(path, FileOptions{})
+	require.NoError(t, err)
+	defer repo.Close()
+
+	problems, err := repo.Verify(ctx)
+	require.NoError(t, err)
+	require.Len(t, problems, 2)
+	assert.Equal(t, ProblemDuplicateOriginal, problems[0].Kind)
+	assert.Equal(t, "a", problems[0].ID)
+	assert.Equal(t, "b", problems[1].ID)
+
+	// Удаление попадает в журнал и переживает перезапуск, а индекс переходит к оставшейся записи
+	n, err := repo.Remove(ctx, []string{"b"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	require.NoError(t, repo.Close())
+
+	reopened, err := NewFileURLRepository(path, FileOptions{})
+	require.NoError(t, err)
+	defer reopened.Close()
+	u, err := reopened.FindByID(ctx, "b")
+	require.NoError(t, err)
+	assert.Nil(t, u)
+	u, err = reopened.FindByOriginalURL(ctx, "https://same.example")
+	require.NoError(t, err)
+	require.NotNil(t, u)
+	assert.Equal(t, "a", u.ID)
+	problems, err = reopened.Verify(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, problems)
+}
@@ -0,0 +1,384 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"url-shortener/internal/model"
+)
+
+// ClickRepository хранит переходы по коротким ссылкам. Запись идет только пачками:
+// события собираются в буфер сервиса и сбрасываются фоновым писателем.
+type ClickRepository interface {
+	SaveClicks(ctx context.Context, clicks []model.Click) error
+	// ClickStats возвращает статистику ссылки за интервал [from, to) и не более top
+	// самых частых источников и user agent.
+	ClickStats(ctx context.Context, urlID string, from, to time.Time, top int) (*model.ClickStats, error)
+}
+
+// InMemoryClickRepository хранит только агрегаты переходов, сами события не сохраняются.
+type InMemoryClickRepository struct {
+	mu      sync.RWMutex
+	rollups clickRollups
+}
+
+func NewInMemoryClickRepository() *InMemoryClickRepository {
+	return &InMemoryClickRepository{rollups: make(clickRollups)}
+}
+
+func (r *InMemoryClickRepository) SaveClicks(ctx context.Context, clicks []model.Click) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.addClicks(clicks)
+	return nil
+}
+
+func (r *InMemoryClickRepository) addClicks(clicks []model.Click) {
+	for _, click := range clicks {
+		r.rollups.add(click)
+	}
+}
+
+func (r *InMemoryClickRepository) ClickStats(ctx context.Context, urlID string, from, to time.Time, top int) (*model.ClickStats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.rollups.stats(urlID, from, to, top), nil
+}
+
+// Переходы в файле хранятся так же, как ссылки: журнал пачек (path) и снимок агрегатов
+// (path + ".snapshot"), в который журнал периодически сжимается. В отличие от ссылок, пачки
+// переходов нельзя проиграть повторно, поэтому журнал и снимок помечены номером поколения:
+// первая строка журнала — {"generation":N}, снимок поколения N включает журналы поколений до N.
+// Журнал без заголовка — поколение 0.
+
+type clickGeneration struct {
+	Generation int64 `json:"generation"`
+}
+
+// clickRollupRecord — строка снимка с агрегатами одной ссылки.
+type clickRollupRecord struct {
+	URLID      string                     `json:"url_id"`
+	Hourly     map[int64]int64            `json:"hourly"`
+	Visitors   map[int64][]string         `json:"visitors,omitempty"`
+	Referrers  map[int64]map[string]int64 `json:"referrers"`
+	UserAgents map[int64]map[string]int64 `json:"user_agents"`
+}
+
+func (r *clickRollup) record(urlID string) clickRollupRecord {
+	rec := clickRollupRecord{
+		URLID:      urlID,
+		Hourly:     r.hourly,
+		Visitors:   make(map[int64][]string, len(r.visitors)),
+		Referrers:  r.referrers,
+		UserAgents: r.userAgents,
+	}
+	for day, hashes := range r.visitors {
+		for hash := range hashes {
+			rec.Visitors[day] = append(rec.Visitors[day], hash)
+		}
+	}
+	return rec
+}
+
+func (rec clickRollupRecord) rollup() *clickRollup {
+	r := &clickRollup{
+		hourly:     rec.Hourly,
+		visitors:   make(map[int64]map[string]struct{}, len(rec.Visitors)),
+		referrers:  rec.Referrers,
+		userAgents: rec.UserAgents,
+	}
+	if r.hourly == nil {
+		r.hourly = make(map[int64]int64)
+	}
+	if r.referrers == nil {
+		r.referrers = make(map[int64]map[string]int64)
+	}
+	if r.userAgents == nil {
+		r.userAgents = make(map[int64]map[string]int64)
+	}
+	for day, hashes := range rec.Visitors {
+		r.visitors[day] = make(map[string]struct{}, len(hashes))
+		for _, hash := range hashes {
+			r.visitors[day][hash] = struct{}{}
+		}
+	}
+	return r
+}
+
+// FileClickRepository дописывает каждую пачку одной JSON-строкой в журнал, а агрегаты
+// держит в памяти и восстанавливает при запуске из снимка и журнала. Неполная последняя
+// строка журнала после сбоя отбрасывается.
+type FileClickRepository struct {
+	InMemoryClickRepository
+	path         string
+	snapshotPath string
+	file         *os.File
+	size         int64
+	records      int
+	generation   int64
+
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// NewFileClickRepository открывает хранилище переходов. compactInterval — период сжатия
+// журнала в снимок, 0 — сжатие отключено.
+func NewFileClickRepository(path string, compactInterval time.Duration) (*FileClickRepository, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	repo := &FileClickRepository{
+		InMemoryClickRepository: InMemoryClickRepository{rollups: make(clickRollups)},
+		path:                    path,
+		snapshotPath:            path + snapshotSuffix,
+		stop:                    make(chan struct{}),
+	}
+	snapshotGeneration, err := repo.loadSnapshot()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load clicks snapshot: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open clicks file: %w", err)
+	}
+	repo.file = f
+
+	covered, err := repo.load(snapshotGeneration)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to load clicks from file: %w", err)
+	}
+	// Журнал уже вошел в снимок: сжатие прервалось между записью снимка и заменой журнала
+	if covered {
+		err = repo.resetLog(snapshotGeneration + 1)
+	} else {
+		_, err = repo.file.Seek(repo.size, io.SeekStart)
+	}
+	if err != nil {
+		repo.file.Close()
+		return nil, fmt.Errorf("failed to prepare clicks file: %w", err)
+	}
+
+	if compactInterval > 0 {
+		repo.wg.Add(1)
+		go repo.runCompaction(compactInterval)
+	}
+	return repo, nil
+}
+
+// loadSnapshot читает снимок и возвращает его поколение или -1, если снимка нет.
+func (r *FileClickRepository) loadSnapshot() (int64, error) {
+	f, err := os.Open(r.snapshotPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return -1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(f))
+	var header clickGeneration
+	if err := dec.Decode(&header); err != nil {
+		return 0, fmt.Errorf("failed to read header: %w", err)
+	}
+	for {
+		var rec clickRollupRecord
+		err := dec.Decode(&rec)
+		if errors.Is(err, io.EOF) {
+			return header.Generation, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read rollups: %w", err)
+		}
+		r.rollups[rec.URLID] = rec.rollup()
+	}
+}
+
+// load проигрывает журнал поверх снимка. Если журнал уже вошел в снимок, пачки не применяются
+// и возвращается covered.
+func (r *FileClickRepository) load(snapshotGeneration int64) (covered bool, err error) {
+	reader := bufio.NewReader(r.file)
+	var offset int64
+	first := true
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return false, fmt.Errorf("failed to read file: %w", readErr)
+		}
+
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			if first && trimmed[0] == '{' {
+				var header clickGeneration
+				if err := json.Unmarshal(line, &header); err != nil {
+					return false, fmt.Errorf("corrupted header: %w", err)
+				}
+				r.generation = header.Generation
+			}
+			if first && r.generation <= snapshotGeneration {
+				return true, nil
+			}
+			first = false
+
+			if trimmed[0] != '{' {
+				var clicks []model.Click
+				parseErr := json.Unmarshal(line, &clicks)
+				if parseErr == nil && line[len(line)-1] != '\n' {
+					parseErr = io.ErrUnexpectedEOF
+				}
+				if parseErr != nil {
+					if !errors.Is(readErr, io.EOF) {
+						return false, fmt.Errorf("corrupted record at offset %d: %w", offset, parseErr)
+					}
+					zap.S().Warnw("truncating incomplete clicks record", "offset", offset)
+					if err := r.file.Truncate(offset); err != nil {
+						return false, fmt.Errorf("failed to truncate file: %w", err)
+					}
+					break
+				}
+				r.addClicks(clicks)
+				r.records++
+			}
+		}
+
+		offset += int64(len(line))
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+	}
+	// Пустой журнал без заголовка перекрыт любым снимком
+	if first && r.generation <= snapshotGeneration {
+		return true, nil
+	}
+	r.size = offset
+	return false, nil
+}
+
+func (r *FileClickRepository) runCompaction(interval time.Duration) {
+	defer r.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			if err := r.Compact(); err != nil {
+				zap.S().Errorw("failed to compact clicks file", "error", err)
+			}
+		}
+	}
+}
+
+// Compact сохраняет агрегаты в снимок и начинает журнал следующего поколения.
+// Запись на время сжатия блокируется: пачки копит буфер сервиса.
+func (r *FileClickRepository) Compact() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.records == 0 {
+		return nil
+	}
+	err := writeFileAtomic(r.snapshotPath+tmpSuffix, r.snapshotPath, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		if err := enc.Encode(clickGeneration{Generation: r.generation}); err != nil {
+			return err
+		}
+		for urlID, rollup := range r.rollups {
+			if err := enc.Encode(rollup.record(urlID)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write clicks snapshot: %w", err)
+	}
+	return r.resetLog(r.generation + 1)
+}
+
+// resetLog заменяет журнал пустым журналом поколения generation.
+func (r *FileClickRepository) resetLog(generation int64) error {
+	header, err := json.Marshal(clickGeneration{Generation: generation})
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	header = append(header, '\n')
+	err = writeFileAtomic(r.path+tmpSuffix, r.path, func(w io.Writer) error {
+		_, err := w.Write(header)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to rewrite clicks file: %w", err)
+	}
+
+	f, err := os.OpenFile(r.path, os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to reopen clicks file: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to seek clicks file: %w", err)
+	}
+	r.file.Close()
+	r.file = f
+	r.size = int64(len(header))
+	r.records = 0
+	r.generation = generation
+	return nil
+}
+
+func (r *FileClickRepository) SaveClicks(ctx context.Context, clicks []model.Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+	line, err := json.Marshal(clicks)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	line = append(line, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.file.Write(line); err != nil {
+		// Убираем частично записанную строку, чтобы следующие пачки не оказались после мусора
+		r.file.Truncate(r.size)
+		r.file.Seek(r.size, io.SeekStart)
+		return fmt.Errorf("failed to write clicks: %w", err)
+	}
+	r.size += int64(len(line))
+	r.records++
+	if err := r.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync clicks file: %w", err)
+	}
+	r.addClicks(clicks)
+	return nil
+}
+
+func (r *FileClickRepository) Close() error {
+	var err error
+	r.closeOnce.Do(func() {
+		close(r.stop)
+		r.wg.Wait()
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if closeErr := r.file.Close(); closeErr != nil {
+			err = fmt.Errorf("failed to close clicks file: %w", closeErr)
+		}
+	})
+	return err
+}
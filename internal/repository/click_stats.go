@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"net/url"
+	"sort"
+	"time"
+	"url-shortener/internal/model"
+)
+
+// Статистика строится не по сырым переходам, а по агрегатам, которые обновляются при записи:
+// число переходов по часам, источники и user agent по дням, хеши посетителей по дням.
+// Поэтому время ответа зависит от длины интервала, а не от числа переходов.
+// Источники, user agent и уникальные посетители считаются с точностью до дня.
+
+// maxValueLength ограничивает длину источника и user agent в агрегатах
+const maxValueLength = 256
+
+type clickRollup struct {
+	hourly     map[int64]int64
+	visitors   map[int64]map[string]struct{}
+	referrers  map[int64]map[string]int64
+	userAgents map[int64]map[string]int64
+}
+
+// clickRollups — агрегаты переходов по ID ссылки. Не потокобезопасен.
+type clickRollups map[string]*clickRollup
+
+func (rs clickRollups) add(click model.Click) {
+	r, ok := rs[click.URLID]
+	if !ok {
+		r = &clickRollup{
+			hourly:     make(map[int64]int64),
+			visitors:   make(map[int64]map[string]struct{}),
+			referrers:  make(map[int64]map[string]int64),
+			userAgents: make(map[int64]map[string]int64),
+		}
+		rs[click.URLID] = r
+	}
+
+	t := click.Time.UTC()
+	hour := t.Truncate(time.Hour).Unix()
+	day := startOfDay(t).Unix()
+
+	r.hourly[hour]++
+	if click.IPHash != "" {
+		if r.visitors[day] == nil {
+			r.visitors[day] = make(map[string]struct{})
+		}
+		r.visitors[day][click.IPHash] = struct{}{}
+	}
+	if r.referrers[day] == nil {
+		r.referrers[day] = make(map[string]int64)
+		r.userAgents[day] = make(map[string]int64)
+	}
+	r.referrers[day][referrerHost(click.Referrer)]++
+	r.userAgents[day][truncateValue(click.UserAgent)]++
+}
+
+func (rs clickRollups) stats(urlID string, from, to time.Time, top int) *model.ClickStats {
+	stats := newClickStats(urlID, from, to)
+	r, ok := rs[urlID]
+	if !ok {
+		return stats
+	}
+
+	fromHour, toHour := from.Truncate(time.Hour).Unix(), to.Unix()
+	for hour, clicks := range r.hourly {
+		if hour >= fromHour && hour < toHour {
+			stats.Hourly = append(stats.Hourly, model.StatsPoint{Time: time.Unix(hour, 0).UTC(), Clicks: clicks})
+		}
+	}
+	sort.Slice(stats.Hourly, func(i, j int) bool {
+		return stats.Hourly[i].Time.Before(stats.Hourly[j].Time)
+	})
+	fillTotals(stats)
+
+	fromDay, toDay := startOfDay(from).Unix(), to.Unix()
+	inRange := func(day int64) bool { return day >= fromDay && day < toDay }
+
+	visitors := make(map[string]struct{})
+	referrers := make(map[string]int64)
+	userAgents := make(map[string]int64)
+	for day, hashes := range r.visitors {
+		if inRange(day) {
+			for hash := range hashes {
+				visitors[hash] = struct{}{}
+			}
+		}
+	}
+	for day, counts := range r.referrers {
+		if inRange(day) {
+			for value, clicks := range counts {
+				referrers[value] += clicks
+			}
+		}
+	}
+	for day, counts := range r.userAgents {
+		if inRange(day) {
+			for value, clicks := range counts {
+				userAgents[value] += clicks
+			}
+		}
+	}
+
+	stats.UniqueVisitors = int64(len(visitors))
+	stats.TopReferrers = topEntries(referrers, top)
+	stats.TopUserAgents = topEntries(userAgents, top)
+	return stats
+}
+
+func newClickStats(urlID string, from, to time.Time) *model.ClickStats {
+	return &model.ClickStats{
+		URLID:         urlID,
+		From:          from.UTC(),
+		To:            to.UTC(),
+		Daily:         []model.StatsPoint{},
+		Hourly:        []model.StatsPoint{},
+		TopReferrers:  []model.StatsEntry{},
+		TopUserAgents: []model.StatsEntry{},
+	}
+}
+
+// fillTotals считает общее число переходов и дневную гистограмму по уже заполненной почасовой.
+func fillTotals(stats *model.ClickStats) {
+	for _, point := range stats.Hourly {
+		stats.TotalClicks += point.Clicks
+		day := startOfDay(point.Time)
+		if n := len(stats.Daily); n > 0 && stats.Daily[n-1].Time.Equal(day) {
+			stats.Daily[n-1].Clicks += point.Clicks
+			continue
+		}
+		stats.Daily = append(stats.Daily, model.StatsPoint{Time: day, Clicks: point.Clicks})
+	}
+}
+
+func topEntries(counts map[string]int64, top int) []model.StatsEntry {
+	entries := make([]model.StatsEntry, 0, len(counts))
+	for value, clicks := range counts {
+		entries = append(entries, model.StatsEntry{Value: value, Clicks: clicks})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Clicks != entries[j].Clicks {
+			return entries[i].Clicks > entries[j].Clicks
+		}
+		return entries[i].Value < entries[j].Value
+	})
+	if len(entries) > top {
+		entries = entries[:top]
+	}
+	return entries
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// referrerHost оставляет от источника только хост, чтобы число различных значений не росло
+// вместе с числом страниц. Пустая строка означает прямой переход.
+func referrerHost(referrer string) string {
+	if referrer == "" {
+		return ""
+	}
+	u, err := url.Parse(referrer)
+	if err != nil || u.Host == "" {
+		return truncateValue(referrer)
+	}
+	return u.Host
+}
+
+// truncateValue оставляет первые maxValueLength символов, как left() в миграции 0007,
+// чтобы значения из файла и из базы совпадали и не обрывались посреди символа.
+func truncateValue(value string) string {
+	n := 0
+	for i := range value {
+		if n == maxValueLength {
+			return value[:i]
+		}
+		n++
+	}
+	return value
+}
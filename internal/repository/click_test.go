@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+	"url-shortener/internal/model"
+)
+
+func TestFileClickRepositoryReload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.json.clicks")
+	now := time.Now().UTC().Truncate(time.Second)
+
+	repo, err := NewFileClickRepository(path, 0)
+	require.NoError(t, err)
+	require.NoError(t, repo.SaveClicks(ctx, []model.Click{
+		{URLID: "a", Time: now, Referrer: "https://ref.example", IPHash: "h1"},
+		{URLID: "b", Time: now},
+	}))
+	require.NoError(t, repo.SaveClicks(ctx, []model.Click{{URLID: "a", Time: now}}))
+	require.NoError(t, repo.Close())
+
+	// Эмулируем сбой посреди записи пачки
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(`[{"url_id":"c"`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	reopened, err := NewFileClickRepository(path, 0)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	stats, err := reopened.ClickStats(ctx, "a", now.Add(-time.Hour), now.Add(time.Hour), 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stats.TotalClicks)
+	assert.Equal(t, []model.StatsEntry{{Value: "", Clicks: 1}, {Value: "ref.example", Clicks: 1}}, stats.TopReferrers)
+	stats, err = reopened.ClickStats(ctx, "c", now.Add(-time.Hour), now.Add(time.Hour), 10)
+	require.NoError(t, err)
+	assert.Zero(t, stats.TotalClicks)
+
+	require.NoError(t, reopened.SaveClicks(ctx, []model.Click{{URLID: "d", Time: now}}))
+	require.NoError(t, reopened.Close())
+
+	again, err := NewFileClickRepository(path, 0)
+	require.NoError(t, err)
+	defer again.Close()
+	stats, err = again.ClickStats(ctx, "d", now.Add(-time.Hour), now.Add(time.Hour), 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.TotalClicks)
+}
+
+func TestFileClickRepositoryCompact(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.json.clicks")
+	now := time.Now().UTC().Truncate(time.Second)
+	total := func(repo *FileClickRepository, urlID string) int64 {
+		stats, err := repo.ClickStats(ctx, urlID, now.Add(-time.Hour), now.Add(time.Hour), 10)
+		require.NoError(t, err)
+		return stats.TotalClicks
+	}
+
+	repo, err := NewFileClickRepository(path, 0)
+	require.NoError(t, err)
+	require.NoError(t, repo.SaveClicks(ctx, []model.Click{
+		{URLID: "a", Time: now, Referrer: "https://ref.example", IPHash: "h1"},
+		{URLID: "a", Time: now, IPHash: "h2"},
+	}))
+	journal, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.Compact())
+	require.NoError(t, repo.SaveClicks(ctx, []model.Click{{URLID: "a", Time: now, IPHash: "h1"}}))
+	require.NoError(t, repo.Close())
+
+	// После сжатия в журнале остаются только пачки, записанные позже
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(data)), "\n"), 2)
+
+	reopened, err := NewFileClickRepository(path, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total(reopened, "a"))
+	stats, err := reopened.ClickStats(ctx, "a", now.Add(-time.Hour), now.Add(time.Hour), 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stats.UniqueVisitors)
+	assert.Equal(t, []model.StatsEntry{{Value: "", Clicks: 2}, {Value: "ref.example", Clicks: 1}}, stats.TopReferrers)
+	require.NoError(t, reopened.Compact())
+	require.NoError(t, reopened.Close())
+
+	// Сбой между записью снимка и заменой журнала: старый журнал уже вошел в снимок
+	// и повторно не проигрывается
+	require.NoError(t, os.WriteFile(path, journal, 0644))
+	again, err := NewFileClickRepository(path, 0)
+	require.NoError(t, err)
+	defer again.Close()
+	assert.Equal(t, int64(3), total(again, "a"))
+}
+
+func TestClickStats(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	clicks := []model.Click{
+		{URLID: "a", Time: day.Add(9*time.Hour + 5*time.Minute), Referrer: "https://news.example/post/1", UserAgent: "curl", IPHash: "v1"},
+		{URLID: "a", Time: day.Add(9*time.Hour + 40*time.Minute), Referrer: "https://news.example/post/2", UserAgent: "curl", IPHash: "v1"},
+		{URLID: "a", Time: day.Add(15 * time.Hour), UserAgent: "firefox", IPHash: "v2"},
+		{URLID: "a", Time: day.Add(24*time.Hour + time.Hour), Referrer: "https://blog.example", UserAgent: "curl", IPHash: "v1"},
+		{URLID: "a", Time: day.Add(3 * 24 * time.Hour), UserAgent: "curl", IPHash: "v3"},
+		{URLID: "b", Time: day.Add(9 * time.Hour), IPHash: "v9"},
+	}
+
+	for name, repo := range map[string]ClickRepository{
+		"memory": NewInMemoryClickRepository(),
+		"file": func() ClickRepository {
+			repo, err := NewFileClickRepository(filepath.Join(t.TempDir(), "clicks"), 0)
+			require.NoError(t, err)
+			t.Cleanup(func() { repo.Close() })
+			return repo
+		}(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, repo.SaveClicks(ctx, clicks[:3]))
+			require.NoError(t, repo.SaveClicks(ctx, clicks[3:]))
+
+			stats, err := repo.ClickStats(ctx, "a", day, day.Add(2*24*time.Hour), 1)
+			require.NoError(t, err)
+
+			assert.Equal(t, int64(4), stats.TotalClicks)
+			assert.Equal(t, int64(2), stats.UniqueVisitors)
+			assert.Equal(t, []model.StatsPoint{
+				{Time: day, Clicks: 3},
+				{Time: day.Add(24 * time.Hour), Clicks: 1},
+			}, stats.Daily)
+			assert.Equal(t, []model.StatsPoint{
+				{Time: day.Add(9 * time.Hour), Clicks: 2},
+				{Time: day.Add(15 * time.Hour), Clicks: 1},
+				{Time: day.Add(25 * time.Hour), Clicks: 1},
+			}, stats.Hourly)
+			assert.Equal(t, []model.StatsEntry{{Value: "news.example", Clicks: 2}}, stats.TopReferrers)
+			assert.Equal(t, []model.StatsEntry{{Value: "curl", Clicks: 3}}, stats.TopUserAgents)
+
+			empty, err := repo.ClickStats(ctx, "missing", day, day.Add(time.Hour), 10)
+			require.NoError(t, err)
+			assert.Zero(t, empty.TotalClicks)
+			assert.NotNil(t, empty.Hourly)
+		})
+	}
+}
+
+func TestTruncateValue(t *testing.T) {
+	assert.Equal(t, "short", truncateValue("short"))
+
+	// Длина считается в символах, как в left() у Postgres
+	long := strings.Repeat("я", maxValueLength+10)
+	truncated := truncateValue(long)
+	assert.Equal(t, maxValueLength, utf8.RuneCountInString(truncated))
+	assert.True(t, utf8.ValidString(truncated))
+}
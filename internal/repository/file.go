@@ -0,0 +1,629 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"url-shortener/internal/model"
+)
+
+// Хранилище состоит из двух файлов:
+//   - журнал (filePath) — JSON lines, каждая строка описывает одну операцию и дописывается в конец;
+//   - снимок (filePath + ".snapshot") — JSON lines с полным состоянием на момент последнего сжатия.
+//
+// При загрузке сначала читается снимок, затем поверх него проигрывается журнал. Операции журнала
+// идемпотентны, поэтому повторное проигрывание уже вошедших в снимок записей безопасно.
+
+const (
+	journalOpPut    = "put"
+	journalOpDelete = "delete"
+
+	snapshotSuffix = ".snapshot"
+	tmpSuffix      = ".tmp"
+	// Блокировка берется на отдельный файл: журнал и снимок при сжатии заменяются переименованием,
+	// и flock на старом файле перестал бы что-либо защищать
+	lockSuffix = ".lock"
+)
+
+var (
+	// ErrLocked — хранилище открыто другим процессом, например работающим сервером
+	ErrLocked = errors.New("file storage is locked by another process")
+	// ErrReadOnly — запись в хранилище, открытое только для чтения
+	ErrReadOnly = errors.New("file storage is opened read-only")
+)
+
+type journalRecord struct {
+	Op string `json:"op"`
+	// URLs сохраняются одной строкой, чтобы пачка переживала сбой целиком или не переживала вовсе
+	URLs []*model.URL `json:"urls,omitempty"`
+	// IDs — удаляемые записи для операции delete
+	IDs []string `json:"ids,omitempty"`
+}
+
+type FileOptions struct {
+	// SyncInterval — период fsync журнала. 0 — fsync после каждой записи.
+	SyncInterval time.Duration
+	// CompactInterval — период фонового сжатия журнала в снимок. 0 — сжатие отключено.
+	CompactInterval time.Duration
+	// ReadOnly открывает хранилище только для чтения под разделяемой блокировкой: файлы не меняются,
+	// недописанная последняя строка журнала пропускается без обрезки, фоновые задачи не запускаются.
+	ReadOnly bool
+}
+
+type FileURLRepository struct {
+	mu sync.RWMutex
+	urlIndex
+	filePath     string
+	snapshotPath string
+	opts         FileOptions
+
+	lock           *os.File
+	journal        *os.File
+	journalSize    int64
+	journalRecords int
+	dirty          bool
+
+	// compactMu не дает двум сжатиям выполняться одновременно
+	compactMu sync.Mutex
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+func NewFileURLRepository(filePath string, opts FileOptions) (*FileURLRepository, error) {
+	repo := &FileURLRepository{
+		urlIndex:     newURLIndex(),
+		filePath:     filePath,
+		snapshotPath: filePath + snapshotSuffix,
+		opts:         opts,
+		stop:         make(chan struct{}),
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	lock, err := lockFile(filePath+lockSuffix, opts.ReadOnly)
+	if err != nil {
+		return nil, err
+	}
+	repo.lock = lock
+
+	// Загружаем данные из файла при инициализации
+	if err := repo.loadFromFile(); err != nil {
+		unlockFile(lock)
+		return nil, fmt.Errorf("failed to load data from file: %w", err)
+	}
+	if opts.ReadOnly {
+		return repo, nil
+	}
+
+	journal, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		unlockFile(lock)
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	repo.journal = journal
+
+	if opts.SyncInterval > 0 {
+		repo.runEvery(opts.SyncInterval, repo.syncJournal)
+	}
+	if opts.CompactInterval > 0 {
+		repo.runEvery(opts.CompactInterval, repo.Compact)
+	}
+	return repo, nil
+}
+
+func (r *FileURLRepository) runEvery(interval time.Duration, fn func() error) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				if err := fn(); err != nil {
+					zap.S().Errorw("file storage background task failed", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+func (r *FileURLRepository) loadFromFile() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.replayFile(r.snapshotPath, false); err != nil {
+		return fmt.Errorf("failed to load snapshot: %w", err)
+	}
+
+	legacy, err := isLegacyFile(r.filePath)
+	if err != nil {
+		return err
+	}
+	if legacy {
+		return r.importLegacyFile(!r.opts.ReadOnly)
+	}
+
+	if err := r.replayFile(r.filePath, true); err != nil {
+		return fmt.Errorf("failed to replay journal: %w", err)
+	}
+	return nil
+}
+
+// replayFile применяет к состоянию все записи файла. Если tolerateTail выставлен, недописанная
+// последняя строка (например, после сбоя посреди записи) отбрасывается, а файл обрезается.
+// В режиме только для чтения строка лишь пропускается: ее может прямо сейчас дописывать другой процесс.
+func (r *FileURLRepository) replayFile(path string, tolerateTail bool) error {
+	flag := os.O_RDWR
+	if r.opts.ReadOnly {
+		flag = os.O_RDONLY
+	}
+	f, err := os.OpenFile(path, flag, 0644)
+	if errors.Is(err, os.ErrNotExist) {
+		zap.S().Infow("file does not exist", "path", path)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var offset int64
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return fmt.Errorf("failed to read file: %w", readErr)
+		}
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			var rec journalRecord
+			parseErr := json.Unmarshal(line, &rec)
+			if parseErr == nil && line[len(line)-1] != '\n' {
+				parseErr = io.ErrUnexpectedEOF
+			}
+			if parseErr != nil {
+				if tolerateTail && errors.Is(readErr, io.EOF) {
+					if r.opts.ReadOnly {
+						zap.S().Warnw("skipping incomplete record", "path", path, "offset", offset)
+						r.journalSize = offset
+						return nil
+					}
+					zap.S().Warnw("truncating incomplete record", "path", path, "offset", offset)
+					if err := f.Truncate(offset); err != nil {
+						return fmt.Errorf("failed to truncate file: %w", err)
+					}
+					r.journalSize = offset
+					return nil
+				}
+				return fmt.Errorf("corrupted record at offset %d: %w", offset, parseErr)
+			}
+			r.apply(rec)
+			if tolerateTail {
+				r.journalRecords++
+			}
+		}
+
+		offset += int64(len(line))
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+	}
+
+	if tolerateTail {
+		r.journalSize = offset
+	}
+	return nil
+}
+
+func (r *FileURLRepository) apply(rec journalRecord) {
+	switch rec.Op {
+	case journalOpPut:
+		for _, url := range rec.URLs {
+			r.put(url)
+		}
+	case journalOpDelete:
+		for _, id := range rec.IDs {
+			r.remove(id)
+		}
+	}
+}
+
+// isLegacyFile определяет старый формат хранилища — один JSON-массив, переписываемый целиком.
+func isLegacyFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	for {
+		b, err := reader.ReadByte()
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to read file: %w", err)
+		}
+		if b == ' ' || b == '\n' || b == '\r' || b == '\t' {
+			continue
+		}
+		return b == '[', nil
+	}
+}
+
+// importLegacyFile загружает старый формат; с migrate данные переносятся в снимок, а файл
+// становится пустым журналом.
+func (r *FileURLRepository) importLegacyFile(migrate bool) error {
+	data, err := os.ReadFile(r.filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	var urls []*model.URL
+	if err := json.Unmarshal(data, &urls); err != nil {
+		return fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	r.apply(journalRecord{Op: journalOpPut, URLs: urls})
+	if !migrate {
+		return nil
+	}
+
+	// Переносим данные в снимок и начинаем журнал с нуля
+	if err := r.writeSnapshot(r.snapshotData()); err != nil {
+		return err
+	}
+	if err := os.Truncate(r.filePath, 0); err != nil {
+		return fmt.Errorf("failed to truncate legacy file: %w", err)
+	}
+	r.journalSize = 0
+	r.journalRecords = 0
+	return nil
+}
+
+// appendRecord дописывает запись в журнал. Вызывается под r.mu.
+func (r *FileURLRepository) appendRecord(rec journalRecord) error {
+	if r.journal == nil {
+		return ErrReadOnly
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	line = append(line, '\n')
+
+	if _, err := r.journal.Write(line); err != nil {
+		// Убираем частично записанную строку, чтобы следующие записи не оказались после мусора
+		r.journal.Truncate(r.journalSize)
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	r.journalSize += int64(len(line))
+	r.journalRecords++
+
+	if r.opts.SyncInterval > 0 {
+		r.dirty = true
+		return nil
+	}
+	if err := r.journal.Sync(); err != nil {
+		return fmt.Errorf("failed to sync journal: %w", err)
+	}
+	return nil
+}
+
+func (r *FileURLRepository) syncJournal() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.dirty {
+		return nil
+	}
+	if err := r.journal.Sync(); err != nil {
+		return fmt.Errorf("failed to sync journal: %w", err)
+	}
+	r.dirty = false
+	return nil
+}
+
+// Compact сохраняет текущее состояние в снимок и удаляет из журнала вошедшие в него записи.
+// Снимок пишется без блокировки записи, журнал переписывается под блокировкой только для хвоста,
+// появившегося за время сжатия.
+func (r *FileURLRepository) Compact() error {
+	if r.opts.ReadOnly {
+		return ErrReadOnly
+	}
+	r.compactMu.Lock()
+	defer r.compactMu.Unlock()
+
+	r.mu.RLock()
+	if r.journalRecords == 0 {
+		r.mu.RUnlock()
+		return nil
+	}
+	urls := r.snapshotData()
+	compactedSize := r.journalSize
+	r.mu.RUnlock()
+
+	if err := r.writeSnapshot(urls); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rewriteJournalTail(compactedSize)
+}
+
+// snapshotData копирует текущее состояние в порядке вставки, чтобы после загрузки
+// снимка сохранился порядок ссылок пользователя. Вызывается под r.mu.
+func (r *FileURLRepository) snapshotData() []*model.URL {
+	ordered := r.ordered()
+	urls := make([]*model.URL, 0, len(ordered))
+	for _, url := range ordered {
+		u := *url
+		urls = append(urls, &u)
+	}
+	return urls
+}
+
+func (r *FileURLRepository) writeSnapshot(urls []*model.URL) error {
+	tmpPath := r.snapshotPath + tmpSuffix
+	err := writeFileAtomic(tmpPath, r.snapshotPath, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		for _, url := range urls {
+			if err := enc.Encode(journalRecord{Op: journalOpPut, URLs: []*model.URL{url}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// rewriteJournalTail заменяет журнал его хвостом, начиная с offset. Вызывается под r.mu.
+func (r *FileURLRepository) rewriteJournalTail(offset int64) error {
+	src, err := os.Open(r.filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer src.Close()
+
+	if _, err := src.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek journal: %w", err)
+	}
+
+	var (
+		tailSize    int64
+		tailRecords int
+	)
+	err = writeFileAtomic(r.filePath+tmpSuffix, r.filePath, func(w io.Writer) error {
+		reader := bufio.NewReader(src)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(line) > 0 {
+				if _, werr := w.Write(line); werr != nil {
+					return werr
+				}
+				tailSize += int64(len(line))
+				tailRecords++
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to rewrite journal: %w", err)
+	}
+
+	journal, err := os.OpenFile(r.filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to reopen journal: %w", err)
+	}
+	r.journal.Close()
+	r.journal = journal
+	r.journalSize = tailSize
+	r.journalRecords = tailRecords
+	r.dirty = false
+	return nil
+}
+
+// writeFileAtomic пишет файл во временный путь, синхронизирует его и переименовывает в целевой.
+func writeFileAtomic(tmpPath, path string, write func(w io.Writer) error) error {
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	if err := write(w); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func (r *FileURLRepository) Create(ctx context.Context, url *model.URL) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if err := r.checkCreate(url, now); err != nil {
+		return err
+	}
+
+	urls := append(r.releaseExpired([]*model.URL{url}, now), url)
+	if err := r.appendRecord(journalRecord{Op: journalOpPut, URLs: urls}); err != nil {
+		return fmt.Errorf("failed to save URL to file: %w", err)
+	}
+
+	for _, u := range urls {
+		r.put(u)
+	}
+	return nil
+}
+
+func (r *FileURLRepository) CreateBatch(ctx context.Context, urls []*model.URL) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	inserted, err := r.resolveBatch(urls, now)
+	if err != nil && !IsConflict(err) {
+		return err
+	}
+	if len(inserted) == 0 {
+		return err
+	}
+
+	inserted = append(r.releaseExpired(inserted, now), inserted...)
+	if saveErr := r.appendRecord(journalRecord{Op: journalOpPut, URLs: inserted}); saveErr != nil {
+		return fmt.Errorf("failed to save URLs to file: %w", saveErr)
+	}
+
+	for _, url := range inserted {
+		r.put(url)
+	}
+	return err
+}
+
+func (r *FileURLRepository) MarkDeleted(ctx context.Context, items []model.URLDeletion) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	urls := r.deletable(items)
+	if len(urls) == 0 {
+		return nil
+	}
+	if err := r.appendRecord(journalRecord{Op: journalOpPut, URLs: urls}); err != nil {
+		return fmt.Errorf("failed to save deletions to file: %w", err)
+	}
+	for _, url := range urls {
+		r.put(url)
+	}
+	return nil
+}
+
+func (r *FileURLRepository) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := r.expired(before)
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if err := r.appendRecord(journalRecord{Op: journalOpDelete, IDs: ids}); err != nil {
+		return 0, fmt.Errorf("failed to save expired URLs removal to file: %w", err)
+	}
+	for _, id := range ids {
+		r.remove(id)
+	}
+	return len(ids), nil
+}
+
+func (r *FileURLRepository) FindByID(ctx context.Context, id string) (*model.URL, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.findByID(id), nil
+}
+
+func (r *FileURLRepository) FindByOriginalURL(ctx context.Context, originalURL string) (*model.URL, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.findByOriginal(originalURL), nil
+}
+
+func (r *FileURLRepository) FindByUser(ctx context.Context, userID, cursor string, limit int) ([]*model.URL, string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.findByUser(userID, cursor, limit)
+}
+
+// CountURLs возвращает число не удаленных ссылок из счетчика индекса, не обходя записи.
+func (r *FileURLRepository) CountURLs(ctx context.Context) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.countURLs(), nil
+}
+
+// CountUsers возвращает число пользователей, создавших хотя бы одну ссылку.
+func (r *FileURLRepository) CountUsers(ctx context.Context) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.countUsers(), nil
+}
+
+// Close останавливает фоновые задачи, сбрасывает журнал на диск и закрывает его.
+func (r *FileURLRepository) Close() error {
+	var err error
+	r.closeOnce.Do(func() {
+		close(r.stop)
+		r.wg.Wait()
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.journal != nil {
+			if syncErr := r.journal.Sync(); syncErr != nil {
+				err = fmt.Errorf("failed to sync journal: %w", syncErr)
+			}
+			if closeErr := r.journal.Close(); closeErr != nil && err == nil {
+				err = fmt.Errorf("failed to close journal: %w", closeErr)
+			}
+		}
+		// Блокировка снимается последней, когда журнал уже сброшен на диск
+		if unlockErr := unlockFile(r.lock); unlockErr != nil && err == nil {
+			err = fmt.Errorf("failed to unlock storage: %w", unlockErr)
+		}
+	})
+	return err
+}
@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"url-shortener/internal/model"
+)
+
+func TestFileRepositoryReplay(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.json")
+
+	repo, err := NewFileURLRepository(path, FileOptions{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Create(ctx, &model.URL{ID: "a", Original: "https://a.example", Short: "http://s/a"}))
+	require.NoError(t, repo.CreateBatch(ctx, []*model.URL{
+		{ID: "b", Original: "https://b.example", Short: "http://s/b"},
+		{ID: "c", Original: "https://c.example", Short: "http://s/c"},
+	}))
+	require.NoError(t, repo.Close())
+
+	reopened, err := NewFileURLRepository(path, FileOptions{})
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	for _, id := range []string{"a", "b", "c"} {
+		u, err := reopened.FindByID(ctx, id)
+		require.NoError(t, err)
+		require.NotNil(t, u, id)
+	}
+}
+
+func TestFileRepositoryReplayDeleteExpired(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.json")
+
+	repo, err := NewFileURLRepository(path, FileOptions{})
+	require.NoError(t, err)
+	past := time.Now().Add(-time.Minute)
+	require.NoError(t, repo.Create(ctx, &model.URL{ID: "a", Original: "https://a.example", Short: "http://s/a", ExpiresAt: &past}))
+	require.NoError(t, repo.Create(ctx, &model.URL{ID: "b", Original: "https://b.example", Short: "http://s/b"}))
+	n, err := repo.DeleteExpired(ctx, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.NoError(t, repo.Close())
+
+	reopened, err := NewFileURLRepository(path, FileOptions{})
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	u, err := reopened.FindByID(ctx, "a")
+	require.NoError(t, err)
+	assert.Nil(t, u)
+	u, err = reopened.FindByID(ctx, "b")
+	require.NoError(t, err)
+	assert.NotNil(t, u)
+}
+
+func TestFileRepositoryTruncatedTail(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.json")
+
+	repo, err := NewFileURLRepository(path, FileOptions{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Create(ctx, &model.URL{ID: "a", Original: "https://a.example", Short: "http://s/a"}))
+	require.NoError(t, repo.Close())
+
+	// Имитируем сбой посреди записи второй строки
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"op":"put","urls":[{"id":"b","orig`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	reopened, err := NewFileURLRepository(path, FileOptions{})
+	require.NoError(t, err)
+
+	u, err := reopened.FindByID(ctx, "a")
+	require.NoError(t, err)
+	assert.NotNil(t, u)
+
+	// После восстановления журнал снова пригоден для записи
+	require.NoError(t, reopened.Create(ctx, &model.URL{ID: "b", Original: "https://b.example", Short: "http://s/b"}))
+	require.NoError(t, reopened.Close())
+
+	again, err := NewFileURLRepository(path, FileOptions{})
+	require.NoError(t, err)
+	defer again.Close()
+
+	u, err = again.FindByID(ctx, "b")
+	require.NoError(t, err)
+	assert.NotNil(t, u)
+}
+
+func TestFileRepositoryReadOnly(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.json")
+
+	repo, err := NewFileURLRepository(path, FileOptions{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Create(ctx, &model.URL{ID: "a", Original: "https://a.example", Short: "http://s/a"}))
+
+	_, err = NewFileURLRepository(path, FileOptions{ReadOnly: true})
+	assert.ErrorIs(t, err, ErrLocked)
+	require.NoError(t, repo.Close())
+
+	// Недописанная строка могла бы принадлежать другому процессу, поэтому файл не обрезается
+	tail := `{"op":"put","urls":[{"id":"b","orig`
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(tail)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	before, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	reader, err := NewFileURLRepository(path, FileOptions{ReadOnly: true})
+	require.NoError(t, err)
+	u, err := reader.FindByID(ctx, "a")
+	require.NoError(t, err)
+	assert.NotNil(t, u)
+	assert.ErrorIs(t, reader.Create(ctx, &model.URL{ID: "c", Original: "https://c.example", Short: "http://s/c"}), ErrReadOnly)
+	assert.ErrorIs(t, reader.Compact(), ErrReadOnly)
+
+	// Читателей может быть несколько, писатель ждет их всех
+	other, err := NewFileURLRepository(path, FileOptions{ReadOnly: true})
+	require.NoError(t, err)
+	require.NoError(t, other.Close())
+	_, err = NewFileURLRepository(path, FileOptions{})
+	assert.ErrorIs(t, err, ErrLocked)
+	require.NoError(t, reader.Close())
+
+	after, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+}
+
+func TestFileRepositoryCorruptedMiddle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urls.json")
+	content := "{\"op\":\"put\",\"urls\":[{\"id\":\"a\",\"original\":\"https://a.example\",\"short\":\"http://s/a\"}]}\n" +
+		"garbage\n" +
+		"{\"op\":\"put\",\"urls\":[{\"id\":\"b\",\"original\":\"https://b.example\",\"short\":\"http://s/b\"}]}\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	_, err := NewFileURLRepository(path, FileOptions{})
+	assert.Error(t, err)
+}
+
+func TestFileRepositoryCompact(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.json")
+
+	repo, err := NewFileURLRepository(path, FileOptions{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Create(ctx, &model.URL{ID: "a", Original: "https://a.example", Short: "http://s/a"}))
+	require.NoError(t, repo.Create(ctx, &model.URL{ID: "b", Original: "https://b.example", Short: "http://s/b"}))
+
+	require.NoError(t, repo.Compact())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+
+	require.NoError(t, repo.Create(ctx, &model.URL{ID: "c", Original: "https://c.example", Short: "http://s/c"}))
+	require.NoError(t, repo.Close())
+
+	reopened, err := NewFileURLRepository(path, FileOptions{})
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	for _, id := range []string{"a", "b", "c"} {
+		u, err := reopened.FindByID(ctx, id)
+		require.NoError(t, err)
+		require.NotNil(t, u, id)
+	}
+}
+
+func TestFileRepositoryLegacyFormat(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.json")
+	legacy := `[
+  {"id": "a", "original": "https://a.example", "short": "http://s/a"}
+]`
+	require.NoError(t, os.WriteFile(path, []byte(legacy), 0644))
+
+	repo, err := NewFileURLRepository(path, FileOptions{})
+	require.NoError(t, err)
+	require.NoError(t, repo.Create(ctx, &model.URL{ID: "b", Original: "https://b.example", Short: "http://s/b"}))
+	require.NoError(t, repo.Close())
+
+	reopened, err := NewFileURLRepository(path, FileOptions{})
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	u, err := reopened.FindByOriginalURL(ctx, "https://a.example")
+	require.NoError(t, err)
+	require.NotNil(t, u)
+	assert.Equal(t, "a", u.ID)
+}
@@ -0,0 +1,297 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+	"url-shortener/internal/model"
+)
+
+// urlIndex — общее in-memory состояние для памяти и файлового хранилища.
+// Не потокобезопасен: синхронизация остается на стороне репозитория.
+type urlIndex struct {
+	data map[string]*model.URL
+	// originalURLs указывает на действующую ссылку, а если таких нет — на последнюю удаленную.
+	// Удаленная или истекшая ссылка не мешает сократить тот же URL заново.
+	originalURLs map[string]string
+	// seq — порядковый номер вставки, по нему строится курсор пагинации
+	seq     map[string]int64
+	lastSeq int64
+	// userURLs хранит ID ссылок пользователя по возрастанию seq
+	userURLs map[string][]string
+	// active — число не удаленных ссылок, поддерживается при каждом изменении
+	active int
+}
+
+func newURLIndex() urlIndex {
+	return urlIndex{
+		data:         make(map[string]*model.URL),
+		originalURLs: make(map[string]string),
+		seq:          make(map[string]int64),
+		userURLs:     make(map[string][]string),
+	}
+}
+
+// put добавляет или заменяет запись. Повторная вставка того же ID не меняет ее позицию.
+func (ix *urlIndex) put(url *model.URL) {
+	if !url.Deleted {
+		ix.active++
+	}
+	if old, exists := ix.data[url.ID]; exists {
+		if !old.Deleted {
+			ix.active--
+		}
+		if old.Original != url.Original && ix.originalURLs[old.Original] == url.ID {
+			delete(ix.originalURLs, old.Original)
+		}
+		if old.UserID != url.UserID {
+			ix.removeFromUser(old.UserID, url.ID)
+			ix.insertToUser(url.UserID, url.ID)
+		}
+	} else {
+		ix.lastSeq++
+		ix.seq[url.ID] = ix.lastSeq
+		ix.appendToUser(url.UserID, url.ID)
+	}
+	if cur := ix.findByOriginal(url.Original); cur == nil || cur.ID == url.ID || cur.Deleted || !url.Deleted {
+		ix.originalURLs[url.Original] = url.ID
+	}
+	ix.data[url.ID] = url
+}
+
+// remove удаляет запись из всех индексов.
+func (ix *urlIndex) remove(id string) {
+	url, exists := ix.data[id]
+	if !exists {
+		return
+	}
+	if ix.originalURLs[url.Original] == id {
+		delete(ix.originalURLs, url.Original)
+	}
+	if !url.Deleted {
+		ix.active--
+	}
+	ix.removeFromUser(url.UserID, id)
+	delete(ix.seq, id)
+	delete(ix.data, id)
+}
+
+// expired возвращает ID ссылок, срок действия которых истек к моменту before.
+func (ix *urlIndex) expired(before time.Time) []string {
+	var ids []string
+	for id, url := range ix.data {
+		if url.Expired(before) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (ix *urlIndex) appendToUser(userID, id string) {
+	if userID == "" {
+		return
+	}
+	ix.userURLs[userID] = append(ix.userURLs[userID], id)
+}
+
+// insertToUser вставляет уже существующую запись на место по ее seq, чтобы список оставался упорядоченным.
+func (ix *urlIndex) insertToUser(userID, id string) {
+	if userID == "" {
+		return
+	}
+	ids := ix.userURLs[userID]
+	seq := ix.seq[id]
+	i := sort.Search(len(ids), func(i int) bool {
+		return ix.seq[ids[i]] > seq
+	})
+	ix.userURLs[userID] = append(ids[:i:i], append([]string{id}, ids[i:]...)...)
+}
+
+func (ix *urlIndex) removeFromUser(userID, id string) {
+	ids := ix.userURLs[userID]
+	for i, v := range ids {
+		if v == id {
+			ix.userURLs[userID] = append(ids[:i:i], ids[i+1:]...)
+			break
+		}
+	}
+	if len(ix.userURLs[userID]) == 0 {
+		delete(ix.userURLs, userID)
+	}
+}
+
+// countURLs возвращает число не удаленных ссылок.
+func (ix *urlIndex) countURLs() int {
+	return ix.active
+}
+
+// countUsers возвращает число пользователей, создавших хотя бы одну ссылку.
+func (ix *urlIndex) countUsers() int {
+	return len(ix.userURLs)
+}
+
+func (ix *urlIndex) findByID(id string) *model.URL {
+	return ix.data[id]
+}
+
+func (ix *urlIndex) findByOriginal(originalURL string) *model.URL {
+	id, exists := ix.originalURLs[originalURL]
+	if !exists {
+		return nil
+	}
+	return ix.data[id]
+}
+
+// occupied возвращает ссылку, которая мешает сократить originalURL заново.
+func (ix *urlIndex) occupied(originalURL string, now time.Time) *model.URL {
+	found := ix.findByOriginal(originalURL)
+	if found == nil || found.Deleted || found.Expired(now) {
+		return nil
+	}
+	return found
+}
+
+// releaseExpired возвращает помеченные удаленными копии истекших ссылок на те же URL, что и urls.
+// Их записывают вместе с urls, чтобы у оригинального URL оставалась одна действующая ссылка.
+func (ix *urlIndex) releaseExpired(urls []*model.URL, now time.Time) []*model.URL {
+	var released []*model.URL
+	for _, url := range urls {
+		if url.Deleted {
+			continue
+		}
+		if found := ix.findByOriginal(url.Original); found != nil && !found.Deleted && found.Expired(now) {
+			updated := *found
+			updated.Deleted = true
+			released = append(released, &updated)
+		}
+	}
+	return released
+}
+
+// findByUser возвращает не более limit ссылок пользователя после курсора и курсор следующей страницы.
+func (ix *urlIndex) findByUser(userID, cursor string, limit int) ([]*model.URL, string, error) {
+	if limit < 1 {
+		return nil, "", ErrInvalidLimit
+	}
+	after, err := parseCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ids := ix.userURLs[userID]
+	start := sort.Search(len(ids), func(i int) bool {
+		return ix.seq[ids[i]] > after
+	})
+
+	urls := make([]*model.URL, 0, limit)
+	next := ""
+	for _, id := range ids[start:] {
+		url := ix.data[id]
+		if url.Deleted {
+			continue
+		}
+		if len(urls) == limit {
+			next = formatCursor(ix.seq[urls[len(urls)-1].ID])
+			break
+		}
+		urls = append(urls, url)
+	}
+	return urls, next, nil
+}
+
+// deletable возвращает обновленные копии ссылок, которые нужно пометить удаленными.
+func (ix *urlIndex) deletable(items []model.URLDeletion) []*model.URL {
+	urls := make([]*model.URL, 0, len(items))
+	for _, item := range items {
+		url := ix.data[item.ID]
+		if url == nil || url.Deleted || url.UserID != item.UserID {
+			continue
+		}
+		updated := *url
+		updated.Deleted = true
+		urls = append(urls, &updated)
+	}
+	return urls
+}
+
+// ordered возвращает все записи в порядке вставки.
+func (ix *urlIndex) ordered() []*model.URL {
+	urls := make([]*model.URL, 0, len(ix.data))
+	for _, url := range ix.data {
+		urls = append(urls, url)
+	}
+	sort.Slice(urls, func(i, j int) bool {
+		return ix.seq[urls[i].ID] < ix.seq[urls[j].ID]
+	})
+	return urls
+}
+
+// resolveBatch подменяет в пачке уже сокращенные URL существующими записями и возвращает
+// записи, которые нужно вставить. Если были подмены, возвращается *ErrConflict для первой из них.
+func (ix *urlIndex) resolveBatch(urls []*model.URL, now time.Time) ([]*model.URL, error) {
+	inserted := make([]*model.URL, 0, len(urls))
+	existing := make(map[int]*model.URL)
+	ids := make(map[string]struct{}, len(urls))
+	originals := make(map[string]struct{}, len(urls))
+
+	for i, url := range urls {
+		// Удаленная запись действующей ссылке не мешает
+		if !url.Deleted {
+			if found := ix.occupied(url.Original, now); found != nil {
+				existing[i] = found
+				continue
+			}
+			if _, exists := originals[url.Original]; exists {
+				return nil, fmt.Errorf("duplicate URL %s in batch", url.Original)
+			}
+			originals[url.Original] = struct{}{}
+		}
+		if _, exists := ix.data[url.ID]; exists {
+			return nil, ErrIDExists
+		}
+		if _, exists := ids[url.ID]; exists {
+			return nil, ErrIDExists
+		}
+		ids[url.ID] = struct{}{}
+		inserted = append(inserted, url)
+	}
+
+	var conflict error
+	for i := range urls {
+		if url, ok := existing[i]; ok {
+			urls[i] = url
+			if conflict == nil {
+				conflict = &ErrConflict{URL: url}
+			}
+		}
+	}
+	return inserted, conflict
+}
+
+// checkCreate проверяет, что запись можно вставить. Удаленная запись действующей ссылке не мешает.
+func (ix *urlIndex) checkCreate(url *model.URL, now time.Time) error {
+	if found := ix.occupied(url.Original, now); found != nil && !url.Deleted {
+		return &ErrConflict{URL: found}
+	}
+	if _, exists := ix.data[url.ID]; exists {
+		return ErrIDExists
+	}
+	return nil
+}
+
+// Курсор — непрозрачная для клиента строка; внутри это порядковый номер последней отданной записи.
+func parseCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	after, err := strconv.ParseInt(cursor, 10, 64)
+	if err != nil || after < 0 {
+		return 0, ErrInvalidCursor
+	}
+	return after, nil
+}
+
+func formatCursor(seq int64) string {
+	return strconv.FormatInt(seq, 10)
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package repository
+
+import "os"
+
+// На платформах без flock хранилище не блокируется: одновременный доступ нескольких
+// процессов остается на совести оператора.
+func lockFile(path string, shared bool) (*os.File, error) {
+	return nil, nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,36 @@
+//go:build unix
+
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockFile берет flock на файл path: разделяемый для чтения, исключительный для записи.
+// Блокировка не ждет: занятое другим процессом хранилище сразу возвращает ErrLocked.
+func lockFile(path string, shared bool) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	how := syscall.LOCK_EX
+	if shared {
+		how = syscall.LOCK_SH
+	}
+	if err := syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, ErrLocked
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// unlockFile снимает блокировку; закрытие файла освобождает flock.
+func unlockFile(f *os.File) error {
+	return f.Close()
+}
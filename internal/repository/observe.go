@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+	"url-shortener/internal/model"
+)
+
+// Operation описывает вызов хранилища для наблюдателя.
+type Operation struct {
+	// Name — имя метода, например CreateBatch
+	Name string
+	// URLID заполняется для операций над одной ссылкой
+	URLID string
+	// Size — размер пачки для пакетных операций и размер страницы для FindByUser
+	Size int
+}
+
+// Observer вызывается перед каждой операцией хранилища и возвращает контекст для нее
+// и функцию, которой по завершении передается ошибка. Ожидаемые конфликты вставки
+// сбоем хранилища не считаются и передаются как nil.
+type Observer func(ctx context.Context, op Operation) (context.Context, func(err error))
+
+// Observe оборачивает repo так, что каждая операция сообщается observe.
+// Если repo поддерживает Pinger, обертка тоже его поддерживает.
+func Observe(repo URLRepository, observe Observer) URLRepository {
+	observed := &observedRepository{repo: repo, observe: observe}
+	if pinger, ok := repo.(Pinger); ok {
+		return &observedPingableRepository{observedRepository: observed, pinger: pinger}
+	}
+	return observed
+}
+
+// IsConflict сообщает, что оригинальный URL уже сокращен и err содержит *ErrConflict.
+func IsConflict(err error) bool {
+	var conflict *ErrConflict
+	return errors.As(err, &conflict)
+}
+
+type observedRepository struct {
+	repo    URLRepository
+	observe Observer
+}
+
+func (r *observedRepository) Create(ctx context.Context, url *model.URL) error {
+	ctx, done := r.observe(ctx, Operation{Name: "Create", URLID: url.ID})
+	err := r.repo.Create(ctx, url)
+	done(storageError(err))
+	return err
+}
+
+func (r *observedRepository) CreateBatch(ctx context.Context, urls []*model.URL) error {
+	ctx, done := r.observe(ctx, Operation{Name: "CreateBatch", Size: len(urls)})
+	err := r.repo.CreateBatch(ctx, urls)
+	done(storageError(err))
+	return err
+}
+
+func (r *observedRepository) FindByID(ctx context.Context, id string) (*model.URL, error) {
+	ctx, done := r.observe(ctx, Operation{Name: "FindByID", URLID: id})
+	url, err := r.repo.FindByID(ctx, id)
+	done(err)
+	return url, err
+}
+
+func (r *observedRepository) FindByOriginalURL(ctx context.Context, originalURL string) (*model.URL, error) {
+	ctx, done := r.observe(ctx, Operation{Name: "FindByOriginalURL"})
+	url, err := r.repo.FindByOriginalURL(ctx, originalURL)
+	done(err)
+	return url, err
+}
+
+func (r *observedRepository) FindByUser(ctx context.Context, userID, cursor string, limit int) ([]*model.URL, string, error) {
+	ctx, done := r.observe(ctx, Operation{Name: "FindByUser", Size: limit})
+	urls, next, err := r.repo.FindByUser(ctx, userID, cursor, limit)
+	done(err)
+	return urls, next, err
+}
+
+func (r *observedRepository) MarkDeleted(ctx context.Context, items []model.URLDeletion) error {
+	ctx, done := r.observe(ctx, Operation{Name: "MarkDeleted", Size: len(items)})
+	err := r.repo.MarkDeleted(ctx, items)
+	done(err)
+	return err
+}
+
+func (r *observedRepository) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	ctx, done := r.observe(ctx, Operation{Name: "DeleteExpired"})
+	n, err := r.repo.DeleteExpired(ctx, before)
+	done(err)
+	return n, err
+}
+
+func (r *observedRepository) CountURLs(ctx context.Context) (int, error) {
+	ctx, done := r.observe(ctx, Operation{Name: "CountURLs"})
+	n, err := r.repo.CountURLs(ctx)
+	done(err)
+	return n, err
+}
+
+func (r *observedRepository) CountUsers(ctx context.Context) (int, error) {
+	ctx, done := r.observe(ctx, Operation{Name: "CountUsers"})
+	n, err := r.repo.CountUsers(ctx)
+	done(err)
+	return n, err
+}
+
+type observedPingableRepository struct {
+	*observedRepository
+	pinger Pinger
+}
+
+func (r *observedPingableRepository) Ping(ctx context.Context) error {
+	ctx, done := r.observe(ctx, Operation{Name: "Ping"})
+	err := r.pinger.Ping(ctx)
+	done(err)
+	return err
+}
+
+// storageError отбрасывает ожидаемые конфликты вставки: занятый ID и уже сокращенный URL.
+func storageError(err error) error {
+	if errors.Is(err, ErrIDExists) || IsConflict(err) {
+		return nil
+	}
+	return err
+}
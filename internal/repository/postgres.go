@@ -0,0 +1,253 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	"time"
+	"url-shortener/internal/model"
+)
+
+const (
+	uniqueViolationCode = "23505"
+	pingTimeout         = time.Second
+
+	urlColumns = "id, original_url, short_url, user_id, is_deleted, created_at, expires_at"
+)
+
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+type PostgresURLRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresURLRepository ожидает, что схема уже приведена к актуальной версии миграциями.
+func NewPostgresURLRepository(db *sql.DB) *PostgresURLRepository {
+	return &PostgresURLRepository{db: db}
+}
+
+// queryer — общее подмножество *sql.DB и *sql.Tx, чтобы одна и та же логика вставки
+// работала как отдельно, так и внутри транзакции пачки.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (r *PostgresURLRepository) Create(ctx context.Context, url *model.URL) error {
+	existing, err := insertURL(ctx, r.db, url)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return &ErrConflict{URL: existing}
+	}
+	return nil
+}
+
+func (r *PostgresURLRepository) CreateBatch(ctx context.Context, urls []*model.URL) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var conflict error
+	for i, url := range urls {
+		existing, err := insertURL(ctx, tx, url)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			urls[i] = existing
+			if conflict == nil {
+				conflict = &ErrConflict{URL: existing}
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return conflict
+}
+
+// insertURL вставляет запись, а если оригинальный URL уже сокращен действующей ссылкой — возвращает ее.
+// Конфликт разрешается самой базой через ON CONFLICT, поэтому гонки между проверкой и вставкой нет.
+// Истекшая ссылка на тот же URL помечается удаленной и вставке не мешает.
+// Удаленная запись в уникальный индекс не попадает и вставляется рядом с действующей.
+func insertURL(ctx context.Context, q queryer, url *model.URL) (*model.URL, error) {
+	if !url.Deleted {
+		if _, err := q.ExecContext(ctx,
+			`UPDATE urls SET is_deleted = TRUE
+			WHERE original_url = $1 AND NOT is_deleted AND expires_at <= now()`,
+			url.Original,
+		); err != nil {
+			return nil, fmt.Errorf("failed to release expired URL: %w", err)
+		}
+	}
+
+	res, err := q.ExecContext(ctx,
+		`INSERT INTO urls (id, original_url, short_url, user_id, created_at, expires_at, is_deleted)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (original_url) WHERE NOT is_deleted DO NOTHING`,
+		url.ID, url.Original, url.Short, url.UserID, url.CreatedAt, url.ExpiresAt, url.Deleted,
+	)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+			return nil, ErrIDExists
+		}
+		return nil, fmt.Errorf("failed to insert URL: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert URL: %w", err)
+	}
+	if affected > 0 {
+		return nil, nil
+	}
+
+	existing, err := findOne(ctx, q, "SELECT "+urlColumns+" FROM urls WHERE original_url = $1 AND NOT is_deleted", url.Original)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, fmt.Errorf("failed to insert URL: conflicting record disappeared")
+	}
+	return existing, nil
+}
+
+func (r *PostgresURLRepository) FindByID(ctx context.Context, id string) (*model.URL, error) {
+	return findOne(ctx, r.db, "SELECT "+urlColumns+" FROM urls WHERE id = $1", id)
+}
+
+// FindByOriginalURL предпочитает действующую ссылку; если все удалены, возвращает последнюю.
+func (r *PostgresURLRepository) FindByOriginalURL(ctx context.Context, originalURL string) (*model.URL, error) {
+	return findOne(ctx, r.db, "SELECT "+urlColumns+" FROM urls WHERE original_url = $1 ORDER BY is_deleted, seq DESC LIMIT 1", originalURL)
+}
+
+func findOne(ctx context.Context, q queryer, query string, arg string) (*model.URL, error) {
+	var url model.URL
+	err := q.QueryRowContext(ctx, query, arg).Scan(&url.ID, &url.Original, &url.Short, &url.UserID, &url.Deleted, &url.CreatedAt, &url.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query URL: %w", err)
+	}
+	return &url, nil
+}
+
+func (r *PostgresURLRepository) FindByUser(ctx context.Context, userID, cursor string, limit int) ([]*model.URL, string, error) {
+	if limit < 1 {
+		return nil, "", ErrInvalidLimit
+	}
+	after, err := parseCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Запрашиваем на одну запись больше, чтобы понять, есть ли следующая страница
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT "+urlColumns+", seq FROM urls WHERE user_id = $1 AND seq > $2 AND NOT is_deleted ORDER BY seq LIMIT $3",
+		userID, after, limit+1,
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query user URLs: %w", err)
+	}
+	defer rows.Close()
+
+	urls := make([]*model.URL, 0, limit)
+	var lastSeq int64
+	hasMore := false
+	for rows.Next() {
+		if len(urls) == limit {
+			hasMore = true
+			break
+		}
+		var url model.URL
+		if err := rows.Scan(&url.ID, &url.Original, &url.Short, &url.UserID, &url.Deleted, &url.CreatedAt, &url.ExpiresAt, &lastSeq); err != nil {
+			return nil, "", fmt.Errorf("failed to scan user URL: %w", err)
+		}
+		urls = append(urls, &url)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to query user URLs: %w", err)
+	}
+
+	next := ""
+	if hasMore {
+		next = formatCursor(lastSeq)
+	}
+	return urls, next, nil
+}
+
+func (r *PostgresURLRepository) MarkDeleted(ctx context.Context, items []model.URLDeletion) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	userIDs := make([]string, 0, len(items))
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		userIDs = append(userIDs, item.UserID)
+		ids = append(ids, item.ID)
+	}
+
+	// Одним запросом обновляем всю пачку; условие на user_id не дает удалить чужие ссылки
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE urls SET is_deleted = TRUE
+		FROM unnest($1::text[], $2::text[]) AS d(user_id, id)
+		WHERE urls.id = d.id AND urls.user_id = d.user_id AND NOT urls.is_deleted`,
+		userIDs, ids,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to mark URLs deleted: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresURLRepository) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM urls WHERE expires_at <= $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired URLs: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired URLs: %w", err)
+	}
+	return int(n), nil
+}
+
+func (r *PostgresURLRepository) CountURLs(ctx context.Context) (int, error) {
+	var n int
+	if err := r.db.QueryRowContext(ctx, `SELECT count(*) FROM urls WHERE NOT is_deleted`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count URLs: %w", err)
+	}
+	return n, nil
+}
+
+// CountUsers считает пользователей, создавших хотя бы одну ссылку, в том числе уже удаленную,
+// как и хранилища в памяти и в файле.
+func (r *PostgresURLRepository) CountUsers(ctx context.Context) (int, error) {
+	var n int
+	if err := r.db.QueryRowContext(ctx, `SELECT count(DISTINCT user_id) FROM urls WHERE user_id <> ''`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+	return n, nil
+}
+
+func (r *PostgresURLRepository) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	return r.db.PingContext(ctx)
+}
+
+func (r *PostgresURLRepository) Close() error {
+	return r.db.Close()
+}
@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+	"url-shortener/internal/model"
+)
+
+// PostgresClickRepository использует соединение PostgresURLRepository и не закрывает его.
+type PostgresClickRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresClickRepository(db *sql.DB) *PostgresClickRepository {
+	return &PostgresClickRepository{db: db}
+}
+
+// SaveClicks в одной транзакции записывает сырые переходы и прибавляет пачку к агрегатам.
+// Пачка сначала сворачивается в памяти, поэтому на каждый агрегат приходится одна строка upsert.
+func (r *PostgresClickRepository) SaveClicks(ctx context.Context, clicks []model.Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertClicks(ctx, tx, clicks); err != nil {
+		return err
+	}
+
+	batch := make(clickRollups)
+	for _, click := range clicks {
+		batch.add(click)
+	}
+	if err := upsertRollups(ctx, tx, batch); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit clicks: %w", err)
+	}
+	return nil
+}
+
+func insertClicks(ctx context.Context, tx *sql.Tx, clicks []model.Click) error {
+	n := len(clicks)
+	urlIDs, referrers, userAgents, ipHashes := make([]string, n), make([]string, n), make([]string, n), make([]string, n)
+	times := make([]time.Time, n)
+	for i, click := range clicks {
+		urlIDs[i] = click.URLID
+		times[i] = click.Time
+		referrers[i] = click.Referrer
+		userAgents[i] = click.UserAgent
+		ipHashes[i] = click.IPHash
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO clicks (url_id, clicked_at, referrer, user_agent, ip_hash)
+		SELECT * FROM unnest($1::text[], $2::timestamptz[], $3::text[], $4::text[], $5::text[])`,
+		urlIDs, times, referrers, userAgents, ipHashes,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save clicks: %w", err)
+	}
+	return nil
+}
+
+// counterRows — столбцы для upsert счетчиков вида (url_id, время, значение, число переходов).
+type counterRows struct {
+	urlIDs []string
+	times  []time.Time
+	values []string
+	counts []int64
+}
+
+func (c *counterRows) add(urlID string, unix int64, value string, count int64) {
+	c.urlIDs = append(c.urlIDs, urlID)
+	c.times = append(c.times, time.Unix(unix, 0).UTC())
+	c.values = append(c.values, value)
+	c.counts = append(c.counts, count)
+}
+
+func upsertRollups(ctx context.Context, tx *sql.Tx, batch clickRollups) error {
+	var hourly, visitors, referrers, userAgents counterRows
+	for urlID, r := range batch {
+		for hour, count := range r.hourly {
+			hourly.add(urlID, hour, "", count)
+		}
+		for day, hashes := range r.visitors {
+			for hash := range hashes {
+				visitors.add(urlID, day, hash, 0)
+			}
+		}
+		for day, counts := range r.referrers {
+			for value, count := range counts {
+				referrers.add(urlID, day, value, count)
+			}
+		}
+		for day, counts := range r.userAgents {
+			for value, count := range counts {
+				userAgents.add(urlID, day, value, count)
+			}
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO click_hourly (url_id, hour, clicks)
+		SELECT * FROM unnest($1::text[], $2::timestamptz[], $3::bigint[])
+		ON CONFLICT (url_id, hour) DO UPDATE SET clicks = click_hourly.clicks + EXCLUDED.clicks`,
+		hourly.urlIDs, hourly.times, hourly.counts,
+	); err != nil {
+		return fmt.Errorf("failed to update hourly clicks: %w", err)
+	}
+
+	if len(visitors.urlIDs) > 0 {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO click_visitors (url_id, day, ip_hash)
+			SELECT * FROM unnest($1::text[], $2::timestamptz[], $3::text[])
+			ON CONFLICT DO NOTHING`,
+			visitors.urlIDs, visitors.times, visitors.values,
+		); err != nil {
+			return fmt.Errorf("failed to update visitors: %w", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO click_referrers (url_id, day, referrer, clicks)
+		SELECT * FROM unnest($1::text[], $2::timestamptz[], $3::text[], $4::bigint[])
+		ON CONFLICT (url_id, day, referrer) DO UPDATE SET clicks = click_referrers.clicks + EXCLUDED.clicks`,
+		referrers.urlIDs, referrers.times, referrers.values, referrers.counts,
+	); err != nil {
+		return fmt.Errorf("failed to update referrers: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO click_user_agents (url_id, day, user_agent, clicks)
+		SELECT * FROM unnest($1::text[], $2::timestamptz[], $3::text[], $4::bigint[])
+		ON CONFLICT (url_id, day, user_agent) DO UPDATE SET clicks = click_user_agents.clicks + EXCLUDED.clicks`,
+		userAgents.urlIDs, userAgents.times, userAgents.values, userAgents.counts,
+	); err != nil {
+		return fmt.Errorf("failed to update user agents: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresClickRepository) ClickStats(ctx context.Context, urlID string, from, to time.Time, top int) (*model.ClickStats, error) {
+	stats := newClickStats(urlID, from, to)
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT hour, clicks FROM click_hourly
+		WHERE url_id = $1 AND hour >= $2 AND hour < $3
+		ORDER BY hour`,
+		urlID, from.Truncate(time.Hour), to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query hourly clicks: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var point model.StatsPoint
+		if err := rows.Scan(&point.Time, &point.Clicks); err != nil {
+			return nil, fmt.Errorf("failed to scan hourly clicks: %w", err)
+		}
+		point.Time = point.Time.UTC()
+		stats.Hourly = append(stats.Hourly, point)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query hourly clicks: %w", err)
+	}
+	fillTotals(stats)
+
+	fromDay := startOfDay(from)
+	err = r.db.QueryRowContext(ctx, `
+		SELECT count(DISTINCT ip_hash) FROM click_visitors
+		WHERE url_id = $1 AND day >= $2 AND day < $3`,
+		urlID, fromDay, to,
+	).Scan(&stats.UniqueVisitors)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count visitors: %w", err)
+	}
+
+	if stats.TopReferrers, err = r.topEntries(ctx, "click_referrers", "referrer", urlID, fromDay, to, top); err != nil {
+		return nil, err
+	}
+	if stats.TopUserAgents, err = r.topEntries(ctx, "click_user_agents", "user_agent", urlID, fromDay, to, top); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// topEntries выбирает самые частые значения из дневного агрегата table.
+// Имена таблицы и столбца передаются только константами из ClickStats.
+func (r *PostgresClickRepository) topEntries(ctx context.Context, table, column, urlID string, from, to time.Time, top int) ([]model.StatsEntry, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+column+`, sum(clicks) AS total FROM `+table+`
+		WHERE url_id = $1 AND day >= $2 AND day < $3
+		GROUP BY `+column+`
+		ORDER BY total DESC, `+column+`
+		LIMIT $4`,
+		urlID, from, to, top,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	entries := []model.StatsEntry{}
+	for rows.Next() {
+		var entry model.StatsEntry
+		if err := rows.Scan(&entry.Value, &entry.Clicks); err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", table, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", table, err)
+	}
+	return entries, nil
+}
@@ -1,197 +1,139 @@
 package repository
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"log"
-	"os"
-	"path/filepath"
 	"sync"
+	"time"
 	"url-shortener/internal/model"
 )
 
+// URLRepository — хранилище ссылок. Все методы принимают контекст запроса: его отмена
+// или истечение дедлайна прерывает обращение к базе данных.
 type URLRepository interface {
-	Create(url *model.URL) error
-	FindByID(id string) (*model.URL, error)
-	FindByOriginalURL(originalURL string) (*model.URL, error)
+	Create(ctx context.Context, url *model.URL) error
+	// CreateBatch сохраняет все записи атомарно: либо все, либо ни одной.
+	// Уже сокращенные URL не считаются ошибкой пачки: соответствующие элементы
+	// заменяются существующими записями, а метод возвращает *ErrConflict.
+	CreateBatch(ctx context.Context, urls []*model.URL) error
+	FindByID(ctx context.Context, id string) (*model.URL, error)
+	FindByOriginalURL(ctx context.Context, originalURL string) (*model.URL, error)
+	// FindByUser возвращает до limit ссылок пользователя в порядке создания, начиная после cursor.
+	// Пустой cursor означает первую страницу; возвращаемый курсор пуст, если страниц больше нет.
+	FindByUser(ctx context.Context, userID, cursor string, limit int) ([]*model.URL, string, error)
+	// MarkDeleted помечает ссылки удаленными. Ссылки, принадлежащие другому пользователю, не меняются.
+	MarkDeleted(ctx context.Context, items []model.URLDeletion) error
+	// DeleteExpired окончательно удаляет ссылки, срок действия которых истек к моменту before,
+	// и возвращает их количество.
+	DeleteExpired(ctx context.Context, before time.Time) (int, error)
+	// CountURLs возвращает число не удаленных ссылок
+	CountURLs(ctx context.Context) (int, error)
+	// CountUsers возвращает число пользователей, создавших хотя бы одну ссылку
+	CountUsers(ctx context.Context) (int, error)
 }
 
+// ErrConflict возвращается, когда оригинальный URL уже сокращен.
+// URL содержит существующую запись.
+type ErrConflict struct {
+	URL *model.URL
+}
+
+func (e *ErrConflict) Error() string {
+	return fmt.Sprintf("URL %s already exists", e.URL.Original)
+}
+
+var (
+	ErrIDExists      = errors.New("ID already exists")
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidLimit  = errors.New("limit must be positive")
+)
+
 type InMemoryURLRepository struct {
-	mu           sync.RWMutex
-	data         map[string]*model.URL
-	originalURLs map[string]string
+	mu sync.RWMutex
+	urlIndex
 }
 
 func NewInMemoryURLRepository() *InMemoryURLRepository {
 	return &InMemoryURLRepository{
-		data:         make(map[string]*model.URL),
-		originalURLs: make(map[string]string),
+		urlIndex: newURLIndex(),
 	}
 }
 
-func (r *InMemoryURLRepository) Create(url *model.URL) error {
+func (r *InMemoryURLRepository) Create(ctx context.Context, url *model.URL) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	if _, exists := r.originalURLs[url.Original]; exists {
-		return fmt.Errorf("URL already exists")
+	now := time.Now()
+	if err := r.checkCreate(url, now); err != nil {
+		return err
 	}
-	r.data[url.ID] = url
-	r.originalURLs[url.Original] = url.ID
+	for _, released := range r.releaseExpired([]*model.URL{url}, now) {
+		r.put(released)
+	}
+	r.put(url)
 	return nil
 }
 
-func (r *InMemoryURLRepository) FindByID(id string) (*model.URL, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	url, exists := r.data[id]
-	if !exists {
-		return nil, nil
+func (r *InMemoryURLRepository) CreateBatch(ctx context.Context, urls []*model.URL) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	inserted, err := r.resolveBatch(urls, now)
+	if err != nil && !IsConflict(err) {
+		return err
 	}
-	return url, nil
+	for _, url := range append(r.releaseExpired(inserted, now), inserted...) {
+		r.put(url)
+	}
+	return err
 }
 
-func (r *InMemoryURLRepository) FindByOriginalURL(originalURL string) (*model.URL, error) {
+func (r *InMemoryURLRepository) FindByID(ctx context.Context, id string) (*model.URL, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-
-	id, exists := r.originalURLs[originalURL]
-	if !exists {
-		return nil, nil
-	}
-
-	url, exists := r.data[id]
-	if !exists {
-		return nil, nil
-	}
-	return url, nil
+	return r.findByID(id), nil
 }
 
-type FileURLRepository struct {
-	mu           sync.RWMutex
-	data         map[string]*model.URL
-	originalURLs map[string]string
-	filePath     string
+func (r *InMemoryURLRepository) FindByOriginalURL(ctx context.Context, originalURL string) (*model.URL, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.findByOriginal(originalURL), nil
 }
 
-func NewFileURLRepository(filePath string) (*FileURLRepository, error) {
-	repo := &FileURLRepository{
-		data:         make(map[string]*model.URL),
-		originalURLs: make(map[string]string),
-		filePath:     filePath,
-	}
-
-	// Загружаем данные из файла при инициализации
-	if err := repo.loadFromFile(); err != nil {
-		return nil, fmt.Errorf("failed to load data from file: %w", err)
-	}
-	return repo, nil
+func (r *InMemoryURLRepository) FindByUser(ctx context.Context, userID, cursor string, limit int) ([]*model.URL, string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.findByUser(userID, cursor, limit)
 }
 
-func (r *FileURLRepository) loadFromFile() error {
+func (r *InMemoryURLRepository) MarkDeleted(ctx context.Context, items []model.URLDeletion) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-
-	if _, err := os.Stat(r.filePath); os.IsNotExist(err) {
-		log.Printf("file %s does not exist", r.filePath)
-		return nil
-	}
-
-	data, err := os.ReadFile(r.filePath)
-	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
-	}
-
-	if len(data) == 0 {
-		return nil
-	}
-
-	var urls []model.URL
-	if err := json.Unmarshal(data, &urls); err != nil {
-		return fmt.Errorf("failed to unmarshal JSON: %w", err)
+	for _, url := range r.deletable(items) {
+		r.put(url)
 	}
-
-	for i := range urls {
-		url := &urls[i]
-		r.data[url.ID] = url
-		r.originalURLs[url.Original] = url.ID
-	}
-
 	return nil
 }
 
-func (r *FileURLRepository) saveToFile() error {
-	urls := make([]model.URL, 0, len(r.data))
-	for _, url := range r.data {
-		urls = append(urls, *url)
-	}
-
-	data, err := json.MarshalIndent(urls, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal JSON: %w", err)
-	}
-
-	dir := filepath.Dir(r.filePath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
-	}
-
-	if err := os.WriteFile(r.filePath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
-	}
-
-	return nil
-}
-
-func (r *FileURLRepository) Create(url *model.URL) error {
+func (r *InMemoryURLRepository) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-
-	if _, exists := r.originalURLs[url.Original]; exists {
-		return fmt.Errorf("URL already exists")
+	ids := r.expired(before)
+	for _, id := range ids {
+		r.remove(id)
 	}
-
-	r.data[url.ID] = url
-	r.originalURLs[url.Original] = url.ID
-
-	if err := r.saveToFile(); err != nil {
-		// Откатываем изменения если сохранение не удалось
-		delete(r.data, url.ID)
-		delete(r.originalURLs, url.Original)
-		return fmt.Errorf("failed to save URL to file: %w", err)
-	}
-
-	return nil
+	return len(ids), nil
 }
 
-func (r *FileURLRepository) FindByID(id string) (*model.URL, error) {
+func (r *InMemoryURLRepository) CountURLs(ctx context.Context) (int, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-
-	url, exists := r.data[id]
-	if !exists {
-		return nil, nil
-	}
-
-	return url, nil
+	return r.countURLs(), nil
 }
 
-func (r *FileURLRepository) FindByOriginalURL(originalURL string) (*model.URL, error) {
+func (r *InMemoryURLRepository) CountUsers(ctx context.Context) (int, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-
-	id, exists := r.originalURLs[originalURL]
-
-	if !exists {
-		return nil, nil
-	}
-	url, exists := r.data[id]
-	if !exists {
-		return nil, nil
-	}
-
-	return url, nil
-}
-
-func (r *FileURLRepository) Close() error {
-	return r.saveToFile()
+	return r.countUsers(), nil
 }
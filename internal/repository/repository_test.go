@@ -0,0 +1,258 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"time"
+	"url-shortener/internal/model"
+)
+
+func newTestRepositories(t *testing.T) map[string]URLRepository {
+	fileRepo, err := NewFileURLRepository(filepath.Join(t.TempDir(), "urls.json"), FileOptions{})
+	require.NoError(t, err)
+	t.Cleanup(func() { fileRepo.Close() })
+
+	return map[string]URLRepository{
+		"memory": NewInMemoryURLRepository(),
+		"file":   fileRepo,
+	}
+}
+
+func TestCreateConflict(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range newTestRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			first := &model.URL{ID: "id1", Original: "https://example.com", Short: "http://s/id1"}
+			require.NoError(t, repo.Create(ctx, first))
+
+			err := repo.Create(ctx, &model.URL{ID: "id2", Original: "https://example.com", Short: "http://s/id2"})
+			var conflict *ErrConflict
+			require.True(t, errors.As(err, &conflict))
+			assert.Equal(t, "id1", conflict.URL.ID)
+
+			err = repo.Create(ctx, &model.URL{ID: "id1", Original: "https://other.example", Short: "http://s/id1"})
+			assert.ErrorIs(t, err, ErrIDExists)
+		})
+	}
+}
+
+func TestCreateBatch(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range newTestRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, repo.Create(ctx, &model.URL{ID: "old", Original: "https://old.example", Short: "http://s/old"}))
+
+			batch := []*model.URL{
+				{ID: "a", Original: "https://a.example", Short: "http://s/a"},
+				{ID: "b", Original: "https://old.example", Short: "http://s/b"},
+			}
+			err := repo.CreateBatch(ctx, batch)
+			var conflict *ErrConflict
+			require.True(t, errors.As(err, &conflict))
+			assert.Equal(t, "old", batch[1].ID)
+
+			u, err := repo.FindByID(ctx, "a")
+			require.NoError(t, err)
+			require.NotNil(t, u)
+
+			// Пачка с занятым ID не должна сохранить ни одной записи
+			err = repo.CreateBatch(ctx, []*model.URL{
+				{ID: "c", Original: "https://c.example", Short: "http://s/c"},
+				{ID: "a", Original: "https://d.example", Short: "http://s/a"},
+			})
+			assert.ErrorIs(t, err, ErrIDExists)
+
+			u, err = repo.FindByID(ctx, "c")
+			require.NoError(t, err)
+			assert.Nil(t, u)
+		})
+	}
+}
+
+func TestFindByUser(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range newTestRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, id := range []string{"a", "b", "c"} {
+				require.NoError(t, repo.Create(ctx, &model.URL{
+					ID: id, Original: "https://" + id + ".example", Short: "http://s/" + id, UserID: "user-1",
+				}))
+			}
+			require.NoError(t, repo.Create(ctx, &model.URL{ID: "x", Original: "https://x.example", Short: "http://s/x", UserID: "user-2"}))
+
+			page, next, err := repo.FindByUser(ctx, "user-1", "", 2)
+			require.NoError(t, err)
+			require.Len(t, page, 2)
+			assert.Equal(t, "a", page[0].ID)
+			assert.Equal(t, "b", page[1].ID)
+			require.NotEmpty(t, next)
+
+			page, next, err = repo.FindByUser(ctx, "user-1", next, 2)
+			require.NoError(t, err)
+			require.Len(t, page, 1)
+			assert.Equal(t, "c", page[0].ID)
+			assert.Empty(t, next)
+
+			page, _, err = repo.FindByUser(ctx, "nobody", "", 10)
+			require.NoError(t, err)
+			assert.Empty(t, page)
+
+			_, _, err = repo.FindByUser(ctx, "user-1", "not-a-cursor", 10)
+			assert.ErrorIs(t, err, ErrInvalidCursor)
+
+			for _, limit := range []int{0, -1} {
+				_, _, err = repo.FindByUser(ctx, "user-1", "", limit)
+				assert.ErrorIs(t, err, ErrInvalidLimit)
+			}
+		})
+	}
+}
+
+func TestIndexOwnerChange(t *testing.T) {
+	ix := newURLIndex()
+	for _, id := range []string{"a", "b", "c"} {
+		owner := "user-1"
+		if id == "b" {
+			owner = "user-2"
+		}
+		ix.put(&model.URL{ID: id, Original: "https://" + id + ".example", UserID: owner})
+	}
+
+	// Запись переходит к другому владельцу на место по порядку вставки, а не в конец списка
+	ix.put(&model.URL{ID: "b", Original: "https://b.example", UserID: "user-1"})
+	assert.Equal(t, []string{"a", "b", "c"}, ix.userURLs["user-1"])
+	assert.NotContains(t, ix.userURLs, "user-2")
+
+	page, _, err := ix.findByUser("user-1", formatCursor(ix.seq["a"]), 10)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, "b", page[0].ID)
+}
+
+func TestMarkDeleted(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range newTestRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, repo.Create(ctx, &model.URL{ID: "a", Original: "https://a.example", Short: "http://s/a", UserID: "user-1"}))
+			require.NoError(t, repo.Create(ctx, &model.URL{ID: "b", Original: "https://b.example", Short: "http://s/b", UserID: "user-2"}))
+
+			require.NoError(t, repo.MarkDeleted(ctx, []model.URLDeletion{
+				{UserID: "user-1", ID: "a"},
+				{UserID: "user-1", ID: "b"},
+			}))
+
+			u, err := repo.FindByID(ctx, "a")
+			require.NoError(t, err)
+			assert.True(t, u.Deleted)
+
+			u, err = repo.FindByID(ctx, "b")
+			require.NoError(t, err)
+			assert.False(t, u.Deleted)
+
+			page, _, err := repo.FindByUser(ctx, "user-1", "", 10)
+			require.NoError(t, err)
+			assert.Empty(t, page)
+
+			// Удаленная ссылка не мешает сократить тот же URL заново
+			require.NoError(t, repo.Create(ctx, &model.URL{ID: "c", Original: "https://a.example", Short: "http://s/c", UserID: "user-1"}))
+			u, err = repo.FindByOriginalURL(ctx, "https://a.example")
+			require.NoError(t, err)
+			assert.Equal(t, "c", u.ID)
+			err = repo.Create(ctx, &model.URL{ID: "d", Original: "https://a.example", Short: "http://s/d"})
+			var conflict *ErrConflict
+			require.True(t, errors.As(err, &conflict))
+			assert.Equal(t, "c", conflict.URL.ID)
+		})
+	}
+}
+
+func TestDeleteExpired(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range newTestRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now()
+			past, future := now.Add(-time.Minute), now.Add(time.Hour)
+			require.NoError(t, repo.Create(ctx, &model.URL{ID: "old", Original: "https://old.example", Short: "http://s/old", UserID: "user-1", ExpiresAt: &past}))
+			require.NoError(t, repo.Create(ctx, &model.URL{ID: "new", Original: "https://new.example", Short: "http://s/new", UserID: "user-1", ExpiresAt: &future}))
+			require.NoError(t, repo.Create(ctx, &model.URL{ID: "forever", Original: "https://forever.example", Short: "http://s/forever"}))
+
+			n, err := repo.DeleteExpired(ctx, now)
+			require.NoError(t, err)
+			assert.Equal(t, 1, n)
+
+			u, err := repo.FindByID(ctx, "old")
+			require.NoError(t, err)
+			assert.Nil(t, u)
+
+			u, err = repo.FindByOriginalURL(ctx, "https://old.example")
+			require.NoError(t, err)
+			assert.Nil(t, u)
+
+			page, _, err := repo.FindByUser(ctx, "user-1", "", 10)
+			require.NoError(t, err)
+			require.Len(t, page, 1)
+			assert.Equal(t, "new", page[0].ID)
+
+			// Истекший оригинальный URL можно сократить заново
+			require.NoError(t, repo.Create(ctx, &model.URL{ID: "again", Original: "https://old.example", Short: "http://s/again"}))
+		})
+	}
+}
+
+func TestCreateOverExpired(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range newTestRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			past := time.Now().Add(-time.Minute)
+			require.NoError(t, repo.Create(ctx, &model.URL{ID: "a", Original: "https://a.example", Short: "http://s/a", ExpiresAt: &past}))
+			require.NoError(t, repo.Create(ctx, &model.URL{ID: "b", Original: "https://b.example", Short: "http://s/b", ExpiresAt: &past}))
+
+			// Истекшая ссылка освобождает URL еще до удаления по расписанию
+			require.NoError(t, repo.Create(ctx, &model.URL{ID: "a2", Original: "https://a.example", Short: "http://s/a2"}))
+			require.NoError(t, repo.CreateBatch(ctx, []*model.URL{{ID: "b2", Original: "https://b.example", Short: "http://s/b2"}}))
+
+			for old, fresh := range map[string]string{"a": "a2", "b": "b2"} {
+				u, err := repo.FindByID(ctx, old)
+				require.NoError(t, err)
+				assert.True(t, u.Deleted, old)
+				u, err = repo.FindByOriginalURL(ctx, u.Original)
+				require.NoError(t, err)
+				assert.Equal(t, fresh, u.ID)
+			}
+			count, err := repo.CountURLs(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, 2, count)
+		})
+	}
+}
+
+func TestCounts(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range newTestRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, repo.Create(ctx, &model.URL{ID: "a", Original: "https://a.example", Short: "http://s/a", UserID: "user-1"}))
+			require.NoError(t, repo.Create(ctx, &model.URL{ID: "b", Original: "https://b.example", Short: "http://s/b", UserID: "user-1"}))
+			require.NoError(t, repo.Create(ctx, &model.URL{ID: "c", Original: "https://c.example", Short: "http://s/c", UserID: "user-2"}))
+			require.NoError(t, repo.Create(ctx, &model.URL{ID: "d", Original: "https://d.example", Short: "http://s/d"}))
+			require.NoError(t, repo.MarkDeleted(ctx, []model.URLDeletion{{UserID: "user-1", ID: "a"}}))
+
+			urls, err := repo.CountURLs(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, 3, urls)
+
+			users, err := repo.CountUsers(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, 2, users)
+
+			_, err = repo.DeleteExpired(ctx, time.Now())
+			require.NoError(t, err)
+			urls, err = repo.CountURLs(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, 3, urls)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	minAliasLength = 3
+	maxAliasLength = 64
+)
+
+var (
+	ErrInvalidAlias = errors.New("invalid alias")
+	ErrAliasTaken   = errors.New("alias already taken")
+)
+
+var aliasRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// reservedAliases совпадают с путями сервиса или зарезервированы под них.
+var reservedAliases = map[string]struct{}{
+	"api":     {},
+	"ping":    {},
+	"metrics": {},
+	"health":  {},
+	"debug":   {},
+	"static":  {},
+	"admin":   {},
+}
+
+func validateAlias(alias string) error {
+	if len(alias) < minAliasLength || len(alias) > maxAliasLength {
+		return fmt.Errorf("%w: length must be between %d and %d", ErrInvalidAlias, minAliasLength, maxAliasLength)
+	}
+	if !aliasRe.MatchString(alias) {
+		return fmt.Errorf("%w: only letters, digits, '-' and '_' are allowed", ErrInvalidAlias)
+	}
+	if _, reserved := reservedAliases[strings.ToLower(alias)]; reserved {
+		return fmt.Errorf("%w: %q is reserved", ErrInvalidAlias, alias)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"sync"
+	"sync/atomic"
+	"time"
+	"url-shortener/internal/logging"
+	"url-shortener/internal/model"
+	"url-shortener/internal/repository"
+	"url-shortener/internal/tracing"
+)
+
+const (
+	defaultClickBufferSize = 10000
+	clickBatchSize         = 1000
+	clickFlushInterval     = time.Second
+)
+
+// ClickInfo — данные запроса, из которых строится событие перехода.
+type ClickInfo struct {
+	Referrer  string
+	UserAgent string
+	IP        string
+}
+
+// clickRecorder принимает события переходов без ожидания: если буфер заполнен, событие
+// отбрасывается и учитывается в счетчике, чтобы аналитика не замедляла редиректы.
+type clickRecorder struct {
+	repo  repository.ClickRepository
+	ipKey []byte
+
+	queue   chan model.Click
+	dropped atomic.Int64
+
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+}
+
+func newClickRecorder(repo repository.ClickRepository, bufferSize int, ipKey []byte) *clickRecorder {
+	if bufferSize <= 0 {
+		bufferSize = defaultClickBufferSize
+	}
+	r := &clickRecorder{
+		repo:  repo,
+		ipKey: ipKey,
+		queue: make(chan model.Click, bufferSize),
+		done:  make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+func (r *clickRecorder) Record(id string, info ClickInfo, now time.Time) {
+	click := model.Click{
+		URLID:     id,
+		Time:      now.UTC(),
+		Referrer:  info.Referrer,
+		UserAgent: info.UserAgent,
+		IPHash:    r.hashIP(info.IP),
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		r.dropped.Add(1)
+		return
+	}
+	select {
+	case r.queue <- click:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// Dropped возвращает число событий, отброшенных из-за переполнения буфера или остановки.
+func (r *clickRecorder) Dropped() int64 {
+	return r.dropped.Load()
+}
+
+// Depth возвращает число событий, ожидающих записи в буфере.
+func (r *clickRecorder) Depth() int {
+	return len(r.queue)
+}
+
+// hashIP заменяет адрес клиента HMAC-хешем с ключом из ClickOptions: уникальных посетителей
+// можно посчитать, а восстановить адрес по хешу перебором нельзя без секрета.
+func (r *clickRecorder) hashIP(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	h := hmac.New(sha256.New, r.ipKey)
+	h.Write([]byte(ip))
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+func (r *clickRecorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(clickFlushInterval)
+	defer ticker.Stop()
+
+	var reportedDrops int64
+	batch := make([]model.Click, 0, clickBatchSize)
+	flush := func() {
+		if dropped := r.dropped.Load(); dropped > reportedDrops {
+			logging.FromContext(context.Background()).Warnw("click buffer is full, events dropped",
+				"dropped", dropped-reportedDrops, "total_dropped", dropped)
+			reportedDrops = dropped
+		}
+		if len(batch) == 0 {
+			return
+		}
+		ctx, span := tracer.Start(context.Background(), "clickRecorder.flush",
+			trace.WithAttributes(attribute.Int("batch.size", len(batch))))
+		err := r.repo.SaveClicks(ctx, batch)
+		if err != nil {
+			logging.FromContext(ctx).Errorw("failed to save clicks", "count", len(batch), "error", err)
+		}
+		tracing.End(span, &err)
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case click, ok := <-r.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, click)
+			if len(batch) >= clickBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// Shutdown перестает принимать события и дожидается записи уже накопленных.
+func (r *clickRecorder) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
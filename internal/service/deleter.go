@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"sync"
+	"time"
+	"url-shortener/internal/logging"
+	"url-shortener/internal/model"
+	"url-shortener/internal/repository"
+	"url-shortener/internal/tracing"
+)
+
+const (
+	deleteQueueSize     = 1024
+	deleteBatchSize     = 500
+	deleteFlushInterval = time.Second
+	// deleteEnqueueTimeout ограничивает ожидание места в очереди, если запись в базу не успевает
+	deleteEnqueueTimeout = 100 * time.Millisecond
+
+	// MaxDeleteIDs ограничивает число ID в одном запросе на удаление.
+	MaxDeleteIDs = deleteQueueSize
+)
+
+var (
+	ErrShuttingDown = errors.New("service is shutting down")
+	ErrOverloaded   = errors.New("deletion queue is full")
+)
+
+// deleter собирает запросы на удаление от всех обработчиков в один канал (fan-in)
+// и применяет их к репозиторию пачками по размеру или по таймеру.
+type deleter struct {
+	repo  repository.URLRepository
+	queue chan model.URLDeletion
+
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+}
+
+func newDeleter(repo repository.URLRepository) *deleter {
+	d := &deleter{
+		repo:  repo,
+		queue: make(chan model.URLDeletion, deleteQueueSize),
+		done:  make(chan struct{}),
+	}
+	go d.run()
+	return d
+}
+
+// Enqueue ставит удаление в очередь. Если очередь не освобождается за deleteEnqueueTimeout,
+// возвращает ErrOverloaded; часть ID к этому моменту уже может быть принята.
+func (d *deleter) Enqueue(ctx context.Context, userID string, ids []string) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return ErrShuttingDown
+	}
+
+	timer := time.NewTimer(deleteEnqueueTimeout)
+	defer timer.Stop()
+	for _, id := range ids {
+		select {
+		case d.queue <- model.URLDeletion{UserID: userID, ID: id}:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			return ErrOverloaded
+		}
+	}
+	return nil
+}
+
+func (d *deleter) run() {
+	defer close(d.done)
+
+	ticker := time.NewTicker(deleteFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]model.URLDeletion, 0, deleteBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, span := tracer.Start(context.Background(), "deleter.flush",
+			trace.WithAttributes(attribute.Int("batch.size", len(batch))))
+		err := d.repo.MarkDeleted(ctx, batch)
+		if err != nil {
+			logging.FromContext(ctx).Errorw("failed to delete URLs", "count", len(batch), "error", err)
+		}
+		tracing.End(span, &err)
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case item, ok := <-d.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, item)
+			if len(batch) >= deleteBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// Shutdown перестает принимать удаления и дожидается применения последней пачки.
+// Enqueue пишет в канал под RLock, поэтому после Lock писателей не остается и канал можно закрыть.
+func (d *deleter) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"time"
+	"url-shortener/internal/logging"
+	"url-shortener/internal/repository"
+	"url-shortener/internal/tracing"
+)
+
+// reaper периодически удаляет из репозитория ссылки с истекшим сроком действия.
+type reaper struct {
+	repo     repository.URLRepository
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func newReaper(repo repository.URLRepository, interval time.Duration) *reaper {
+	r := &reaper{
+		repo:     repo,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+func (r *reaper) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.reap()
+		}
+	}
+}
+
+func (r *reaper) reap() {
+	ctx, span := tracer.Start(context.Background(), "reaper.reap")
+	n, err := r.repo.DeleteExpired(ctx, time.Now())
+	tracing.End(span, &err)
+	if err != nil {
+		logging.FromContext(ctx).Errorw("failed to delete expired URLs", "error", err)
+		return
+	}
+	if n > 0 {
+		logging.FromContext(ctx).Infow("deleted expired URLs", "count", n)
+	}
+}
+
+func (r *reaper) Shutdown(ctx context.Context) error {
+	select {
+	case <-r.stop:
+	default:
+		close(r.stop)
+	}
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
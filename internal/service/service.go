@@ -1,71 +1,511 @@
 package service
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+	"url-shortener/internal/logging"
+	"url-shortener/internal/metrics"
 	"url-shortener/internal/model"
 	"url-shortener/internal/repository"
+	"url-shortener/internal/tracing"
 )
 
+var tracer = tracing.Tracer("service")
+
+// Ограничения запросов, общие для HTTP и gRPC. Ограничение на удаление — MaxDeleteIDs.
+const (
+	// MaxBatchURLs ограничивает число URL в одном запросе на пакетное сокращение.
+	MaxBatchURLs = 1000
+	// DefaultUserURLsLimit — размер страницы ссылок пользователя, если клиент его не указал.
+	DefaultUserURLsLimit = 100
+	// MaxUserURLsLimit ограничивает размер страницы ссылок пользователя.
+	MaxUserURLsLimit = 1000
+)
+
+// URLService — бизнес-логика сокращателя. Контекст запроса передается до хранилища.
 type URLService interface {
-	ShortenURL(original string) (*model.URL, error)
-	GetOriginalURL(id string) (string, error)
+	ShortenURL(ctx context.Context, original string, opts ShortenOptions) (*model.URL, error)
+	ShortenBatch(ctx context.Context, items []model.BatchRequestItem, userID string) ([]model.BatchResponseItem, error)
+	GetOriginalURL(ctx context.Context, id string) (string, error)
+	GetUserURLs(ctx context.Context, userID, cursor string, limit int) ([]model.UserURL, string, error)
+	// DeleteUserURLs ставит ссылки пользователя в очередь на удаление и не ждет его выполнения
+	DeleteUserURLs(ctx context.Context, userID string, ids []string) error
+	// GetURLStats возвращает статистику переходов по ссылке пользователя
+	GetURLStats(ctx context.Context, userID, id string, query StatsQuery) (*model.ClickStats, error)
+	// GetServiceStats возвращает число ссылок и пользователей сервиса
+	GetServiceStats(ctx context.Context) (*model.ServiceStats, error)
+	// RecordClick учитывает переход по ссылке в фоне, не задерживая редирект
+	RecordClick(ctx context.Context, id string, info ClickInfo)
+	// DroppedClicks возвращает число переходов, не попавших в аналитику из-за переполнения буфера
+	DroppedClicks() int64
+	Ping(ctx context.Context) error
+	// Shutdown дожидается завершения фоновых задач сервиса
+	Shutdown(ctx context.Context) error
 }
+
+var (
+	ErrStorageNotPingable = errors.New("storage does not support ping")
+	ErrIDGeneration       = errors.New("failed to generate unique ID")
+	ErrDeleted            = errors.New("URL has been deleted")
+	ErrExpired            = errors.New("URL has expired")
+	ErrInvalidExpiry      = errors.New("invalid expiry")
+	ErrNotFound           = errors.New("URL not found")
+	ErrInvalidStatsQuery  = errors.New("invalid stats query")
+	ErrStatsUnavailable   = errors.New("click statistics are not enabled")
+)
+
 type urlService struct {
 	repo    repository.URLRepository
 	baseURL string
+	deleter *deleter
+	reaper  *reaper
+	clicks  *clickRecorder
+
+	reapInterval time.Duration
+	timeouts     Timeouts
+	clickRepo    repository.ClickRepository
+	clickOpts    ClickOptions
+	metrics      *metrics.Metrics
+	now          func() time.Time
+}
+
+type Option func(*urlService)
+
+// WithReapInterval включает фоновое удаление ссылок с истекшим сроком действия.
+func WithReapInterval(interval time.Duration) Option {
+	return func(s *urlService) {
+		s.reapInterval = interval
+	}
+}
+
+// Timeouts ограничивает длительность операций с хранилищем в рамках одного запроса; 0 — без ограничения.
+// Срок из контекста запроса, если он короче, продолжает действовать.
+type Timeouts struct {
+	// Lookup — поиск ссылки для редиректа и список ссылок пользователя
+	Lookup time.Duration
+	// Store — сохранение новых ссылок
+	Store time.Duration
+	// Stats — статистика переходов и сводка по сервису
+	Stats time.Duration
+}
+
+// WithTimeouts задает сроки выполнения операций с хранилищем.
+func WithTimeouts(t Timeouts) Option {
+	return func(s *urlService) {
+		s.timeouts = t
+	}
 }
 
-func NewURLService(repo repository.URLRepository, baseURL string) URLService {
-	return &urlService{
+// WithMetrics включает учет результатов сокращения, редиректов и состояния буфера аналитики.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(s *urlService) {
+		s.metrics = m
+	}
+}
+
+// ClickOptions настраивает запись переходов.
+type ClickOptions struct {
+	// BufferSize — емкость буфера событий; при переполнении новые события отбрасываются
+	BufferSize int
+	// IPKey — ключ HMAC для хеширования IP-адресов; не должен совпадать с ключом подписи cookie
+	IPKey []byte
+}
+
+// WithClicks включает запись переходов по ссылкам в repo.
+func WithClicks(repo repository.ClickRepository, opts ClickOptions) Option {
+	return func(s *urlService) {
+		s.clickRepo = repo
+		s.clickOpts = opts
+	}
+}
+
+func NewURLService(repo repository.URLRepository, baseURL string, opts ...Option) URLService {
+	s := &urlService{
 		repo:    repo,
 		baseURL: baseURL,
+		now:     time.Now,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.deleter = newDeleter(repo)
+	if s.reapInterval > 0 {
+		s.reaper = newReaper(repo, s.reapInterval)
+	}
+	if s.clickRepo != nil {
+		s.clicks = newClickRecorder(s.clickRepo, s.clickOpts.BufferSize, s.clickOpts.IPKey)
+		s.metrics.RegisterClickBuffer(s.clicks.Depth, s.clicks.Dropped)
+	}
+	return s
 }
 
-func (s *urlService) ShortenURL(originalURL string) (*model.URL, error) {
+// ShortenOptions — необязательные параметры сокращения.
+type ShortenOptions struct {
+	UserID string
+	// Alias задает ID короткой ссылки вместо случайного
+	Alias string
+	// ExpiresAt или TTL ограничивают срок действия ссылки; задать можно только одно из них
+	ExpiresAt *time.Time
+	TTL       time.Duration
+}
+
+// maxIDAttempts ограничивает число попыток подобрать свободный ID при коллизиях.
+const maxIDAttempts = 5
+
+// ShortenURL сокращает URL. Если он уже был сокращен, возвращается существующая запись
+// вместе с *repository.ErrConflict. Занятый алиас возвращает ErrAliasTaken.
+func (s *urlService) ShortenURL(ctx context.Context, originalURL string, opts ShortenOptions) (*model.URL, error) {
+	ctx, cancel := withTimeout(ctx, s.timeouts.Store)
+	defer cancel()
+	ctx, span := tracer.Start(ctx, "URLService.ShortenURL")
+	url, err := s.shortenURL(ctx, originalURL, opts)
+	s.endShortenSpan(ctx, span, err)
+	return url, err
+}
 
-	existingURL, err := s.repo.FindByOriginalURL(originalURL)
+func (s *urlService) shortenURL(ctx context.Context, originalURL string, opts ShortenOptions) (*model.URL, error) {
+	if opts.Alias != "" {
+		if err := validateAlias(opts.Alias); err != nil {
+			return nil, err
+		}
+	}
+	expiresAt, err := s.expiresAt(opts)
 	if err != nil {
 		return nil, err
 	}
-	if existingURL != nil {
-		return existingURL, nil
+
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		// Хранилище в памяти не смотрит на контекст, поэтому отмену проверяем перед каждой попыткой
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		id := opts.Alias
+		if id == "" {
+			id = generateID(10)
+		}
+		url := s.newURL(id, originalURL, opts.UserID)
+		url.ExpiresAt = expiresAt
+
+		err := s.repo.Create(ctx, url)
+		if errors.Is(err, repository.ErrIDExists) {
+			if opts.Alias != "" {
+				return nil, ErrAliasTaken
+			}
+			logging.FromContext(ctx).Debugw("short ID collision, retrying", "id", id, "attempt", attempt+1)
+			continue
+		}
+		var conflict *repository.ErrConflict
+		if errors.As(err, &conflict) {
+			return conflict.URL, err
+		}
+		if err != nil {
+			return nil, err
+		}
+		return url, nil
+	}
+	return nil, ErrIDGeneration
+}
+
+// ShortenBatch сокращает пачку URL. Если часть из них уже была сокращена, в ответе будут
+// существующие ссылки, а ошибка будет *repository.ErrConflict.
+func (s *urlService) ShortenBatch(ctx context.Context, items []model.BatchRequestItem, userID string) ([]model.BatchResponseItem, error) {
+	ctx, cancel := withTimeout(ctx, s.timeouts.Store)
+	defer cancel()
+	ctx, span := tracer.Start(ctx, "URLService.ShortenBatch", trace.WithAttributes(attribute.Int("batch.size", len(items))))
+	result, err := s.shortenBatch(ctx, items, userID)
+	s.endShortenSpan(ctx, span, err)
+	return result, err
+}
+
+func (s *urlService) shortenBatch(ctx context.Context, items []model.BatchRequestItem, userID string) ([]model.BatchResponseItem, error) {
+	// Одинаковые URL внутри пачки получают одну и ту же короткую ссылку
+	indexByOriginal := make(map[string]int, len(items))
+	originals := make([]string, 0, len(items))
+	for _, item := range items {
+		if _, ok := indexByOriginal[item.OriginalURL]; !ok {
+			indexByOriginal[item.OriginalURL] = len(originals)
+			originals = append(originals, item.OriginalURL)
+		}
 	}
 
-	var id string
-	for {
-		id = generateID(10)
-		u, _ := s.repo.FindByID(id)
-		if u == nil {
+	var (
+		urls []*model.URL
+		err  error
+	)
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		urls = make([]*model.URL, 0, len(originals))
+		for _, original := range originals {
+			urls = append(urls, s.newURL(generateID(10), original, userID))
+		}
+		err = s.repo.CreateBatch(ctx, urls)
+		if !errors.Is(err, repository.ErrIDExists) {
 			break
 		}
 	}
+	if errors.Is(err, repository.ErrIDExists) {
+		return nil, ErrIDGeneration
+	}
+	if err != nil && !repository.IsConflict(err) {
+		return nil, err
+	}
 
-	url := &model.URL{
-		ID:       id,
-		Original: originalURL,
-		Short:    s.baseURL + "/" + id,
+	result := make([]model.BatchResponseItem, 0, len(items))
+	for _, item := range items {
+		result = append(result, model.BatchResponseItem{
+			CorrelationID: item.CorrelationID,
+			ShortURL:      urls[indexByOriginal[item.OriginalURL]].Short,
+		})
 	}
+	return result, err
+}
 
-	if err := s.repo.Create(url); err != nil {
-		return nil, err
+func (s *urlService) newURL(id, originalURL, userID string) *model.URL {
+	return &model.URL{
+		ID:        id,
+		Original:  originalURL,
+		Short:     s.baseURL + "/" + id,
+		UserID:    userID,
+		CreatedAt: s.now().UTC(),
 	}
+}
 
-	return url, nil
+func (s *urlService) expiresAt(opts ShortenOptions) (*time.Time, error) {
+	switch {
+	case opts.ExpiresAt != nil && opts.TTL != 0:
+		return nil, fmt.Errorf("%w: only one of expires_at and ttl can be set", ErrInvalidExpiry)
+	case opts.TTL < 0:
+		return nil, fmt.Errorf("%w: ttl must be positive", ErrInvalidExpiry)
+	case opts.TTL > 0:
+		expiresAt := s.now().Add(opts.TTL).UTC()
+		return &expiresAt, nil
+	case opts.ExpiresAt != nil:
+		if !opts.ExpiresAt.After(s.now()) {
+			return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidExpiry)
+		}
+		expiresAt := opts.ExpiresAt.UTC()
+		return &expiresAt, nil
+	}
+	return nil, nil
 }
 
-func (s *urlService) GetOriginalURL(id string) (string, error) {
-	url, err := s.repo.FindByID(id)
+func (s *urlService) GetOriginalURL(ctx context.Context, id string) (string, error) {
+	ctx, cancel := withTimeout(ctx, s.timeouts.Lookup)
+	defer cancel()
+	ctx, span := tracer.Start(ctx, "URLService.GetOriginalURL", trace.WithAttributes(attribute.String("url.id", id)))
+	original, err := s.getOriginalURL(ctx, id)
+
+	result := redirectResult(original, err)
+	s.metrics.ObserveRedirect(result)
+	span.SetAttributes(attribute.String("redirect.result", result))
+	if result != metrics.RedirectError {
+		// Удаленные и истекшие ссылки — штатный ответ, а не сбой
+		span.End()
+	} else {
+		logFailure(ctx, "failed to resolve short URL", err, "id", id)
+		tracing.End(span, &err)
+	}
+	return original, err
+}
+
+func (s *urlService) getOriginalURL(ctx context.Context, id string) (string, error) {
+	url, err := s.repo.FindByID(ctx, id)
 	if err != nil {
 		return "", err
 	}
 	if url == nil {
 		return "", nil
 	}
+	if url.Deleted {
+		return "", ErrDeleted
+	}
+	if url.Expired(s.now()) {
+		return "", ErrExpired
+	}
 	return url.Original, nil
 }
 
+// endShortenSpan учитывает результат сокращения в метриках и завершает спан. Конфликты
+// и ошибки валидации — штатные ответы, ошибкой спана отмечаются и в журнал пишутся только сбои.
+func (s *urlService) endShortenSpan(ctx context.Context, span trace.Span, err error) {
+	result := shortenResult(err)
+	s.metrics.ObserveShorten(result)
+	span.SetAttributes(attribute.String("shorten.result", result))
+	if result != metrics.ShortenError {
+		err = nil
+	}
+	logFailure(ctx, "failed to shorten URL", err)
+	tracing.End(span, &err)
+}
+
+// logFailure пишет сбой в журнал запроса. Отключение клиента сбоем не считается.
+func logFailure(ctx context.Context, msg string, err error, keysAndValues ...any) {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+	logging.FromContext(ctx).Errorw(msg, append(keysAndValues, "error", err)...)
+}
+
+func shortenResult(err error) string {
+	switch {
+	case err == nil:
+		return metrics.ShortenSuccess
+	case repository.IsConflict(err), errors.Is(err, ErrAliasTaken):
+		return metrics.ShortenConflict
+	case errors.Is(err, ErrInvalidAlias), errors.Is(err, ErrInvalidExpiry):
+		return metrics.ShortenInvalid
+	default:
+		return metrics.ShortenError
+	}
+}
+
+func redirectResult(original string, err error) string {
+	switch {
+	case errors.Is(err, ErrDeleted):
+		return metrics.RedirectDeleted
+	case errors.Is(err, ErrExpired):
+		return metrics.RedirectExpired
+	case err != nil:
+		return metrics.RedirectError
+	case original == "":
+		return metrics.RedirectMiss
+	default:
+		return metrics.RedirectHit
+	}
+}
+
+func (s *urlService) GetUserURLs(ctx context.Context, userID, cursor string, limit int) (_ []model.UserURL, _ string, err error) {
+	ctx, cancel := withTimeout(ctx, s.timeouts.Lookup)
+	defer cancel()
+	ctx, span := tracer.Start(ctx, "URLService.GetUserURLs")
+	defer tracing.End(span, &err)
+
+	urls, next, err := s.repo.FindByUser(ctx, userID, cursor, limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	result := make([]model.UserURL, 0, len(urls))
+	for _, url := range urls {
+		result = append(result, model.UserURL{
+			ShortURL:    url.Short,
+			OriginalURL: url.Original,
+		})
+	}
+	return result, next, nil
+}
+
+func (s *urlService) DeleteUserURLs(ctx context.Context, userID string, ids []string) (err error) {
+	ctx, span := tracer.Start(ctx, "URLService.DeleteUserURLs", trace.WithAttributes(attribute.Int("batch.size", len(ids))))
+	defer tracing.End(span, &err)
+	return s.deleter.Enqueue(ctx, userID, ids)
+}
+
+func (s *urlService) GetURLStats(ctx context.Context, userID, id string, query StatsQuery) (_ *model.ClickStats, err error) {
+	ctx, cancel := withTimeout(ctx, s.timeouts.Stats)
+	defer cancel()
+	ctx, span := tracer.Start(ctx, "URLService.GetURLStats", trace.WithAttributes(attribute.String("url.id", id)))
+	defer tracing.End(span, &err)
+
+	if s.clickRepo == nil {
+		return nil, ErrStatsUnavailable
+	}
+	if err := query.normalize(s.now()); err != nil {
+		return nil, err
+	}
+
+	url, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// Чужие и удаленные ссылки неотличимы от несуществующих
+	if url == nil || url.Deleted || url.UserID != userID {
+		return nil, ErrNotFound
+	}
+	// ID освобождается после окончательного удаления, и переходы по прежней ссылке
+	// с тем же ID не должны попадать в статистику новой
+	if !query.To.After(url.CreatedAt) {
+		return emptyStats(id, query), nil
+	}
+	if query.From.Before(url.CreatedAt) {
+		query.From = url.CreatedAt
+	}
+	return s.clickRepo.ClickStats(ctx, id, query.From, query.To, query.Top)
+}
+
+func (s *urlService) GetServiceStats(ctx context.Context) (_ *model.ServiceStats, err error) {
+	ctx, cancel := withTimeout(ctx, s.timeouts.Stats)
+	defer cancel()
+	ctx, span := tracer.Start(ctx, "URLService.GetServiceStats")
+	defer tracing.End(span, &err)
+
+	urls, err := s.repo.CountURLs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	users, err := s.repo.CountUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &model.ServiceStats{URLs: urls, Users: users}, nil
+}
+
+// RecordClick не открывает собственный спан: постановка в буфер не блокируется,
+// а запись пачки выполняется вне запроса.
+func (s *urlService) RecordClick(ctx context.Context, id string, info ClickInfo) {
+	if s.clicks == nil {
+		return
+	}
+	s.clicks.Record(id, info, s.now())
+}
+
+func (s *urlService) DroppedClicks() int64 {
+	if s.clicks == nil {
+		return 0
+	}
+	return s.clicks.Dropped()
+}
+
+func (s *urlService) Ping(ctx context.Context) error {
+	pinger, ok := s.repo.(repository.Pinger)
+	if !ok {
+		return ErrStorageNotPingable
+	}
+	return pinger.Ping(ctx)
+}
+
+func (s *urlService) Shutdown(ctx context.Context) error {
+	var errs []error
+	if s.reaper != nil {
+		if err := s.reaper.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := s.deleter.Shutdown(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	if s.clicks != nil {
+		if err := s.clicks.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// withTimeout ограничивает ctx сроком d, если он задан.
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
 func generateID(length int) string {
 	bytes := make([]byte, length)
 	if _, err := rand.Read(bytes); err != nil {
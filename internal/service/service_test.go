@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"url-shortener/internal/metrics"
+	"url-shortener/internal/model"
+	"url-shortener/internal/repository"
+)
+
+func TestDeleteUserURLs(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryURLRepository()
+	svc := NewURLService(repo, "http://localhost:8080")
+
+	own, err := svc.ShortenURL(ctx, "https://own.example", ShortenOptions{UserID: "user-1"})
+	require.NoError(t, err)
+	foreign, err := svc.ShortenURL(ctx, "https://foreign.example", ShortenOptions{UserID: "user-2"})
+	require.NoError(t, err)
+
+	require.NoError(t, svc.DeleteUserURLs(ctx, "user-1", []string{own.ID, foreign.ID, "missing"}))
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	require.NoError(t, svc.Shutdown(shutdownCtx))
+
+	_, err = svc.GetOriginalURL(ctx, own.ID)
+	assert.ErrorIs(t, err, ErrDeleted)
+
+	original, err := svc.GetOriginalURL(ctx, foreign.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "https://foreign.example", original)
+
+	assert.ErrorIs(t, svc.DeleteUserURLs(ctx, "user-1", []string{own.ID}), ErrShuttingDown)
+}
+
+func TestShortenURLAlias(t *testing.T) {
+	ctx := context.Background()
+	svc := NewURLService(repository.NewInMemoryURLRepository(), "http://localhost:8080")
+
+	url, err := svc.ShortenURL(ctx, "https://example.com/sale", ShortenOptions{Alias: "spring-sale"})
+	require.NoError(t, err)
+	assert.Equal(t, "spring-sale", url.ID)
+	assert.Equal(t, "http://localhost:8080/spring-sale", url.Short)
+
+	_, err = svc.ShortenURL(ctx, "https://example.com/other", ShortenOptions{Alias: "spring-sale"})
+	assert.ErrorIs(t, err, ErrAliasTaken)
+
+	for _, alias := range []string{"ab", "has space", "слово", "api", "PING", strings.Repeat("a", 65)} {
+		_, err := svc.ShortenURL(ctx, "https://example.com/"+alias, ShortenOptions{Alias: alias})
+		assert.ErrorIs(t, err, ErrInvalidAlias, alias)
+	}
+}
+
+func TestShortenURLExpiry(t *testing.T) {
+	ctx := context.Background()
+	svc := NewURLService(repository.NewInMemoryURLRepository(), "http://localhost:8080")
+
+	url, err := svc.ShortenURL(ctx, "https://example.com/campaign", ShortenOptions{TTL: time.Hour})
+	require.NoError(t, err)
+	require.NotNil(t, url.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *url.ExpiresAt, time.Minute)
+
+	original, err := svc.GetOriginalURL(ctx, url.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/campaign", original)
+
+	past := time.Now().Add(-time.Minute)
+	_, err = svc.ShortenURL(ctx, "https://example.com/past", ShortenOptions{ExpiresAt: &past})
+	assert.ErrorIs(t, err, ErrInvalidExpiry)
+
+	future := time.Now().Add(time.Hour)
+	_, err = svc.ShortenURL(ctx, "https://example.com/both", ShortenOptions{ExpiresAt: &future, TTL: time.Hour})
+	assert.ErrorIs(t, err, ErrInvalidExpiry)
+
+	_, err = svc.ShortenURL(ctx, "https://example.com/negative", ShortenOptions{TTL: -time.Hour})
+	assert.ErrorIs(t, err, ErrInvalidExpiry)
+}
+
+func TestGetOriginalURLExpired(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryURLRepository()
+	svc := NewURLService(repo, "http://localhost:8080")
+
+	url, err := svc.ShortenURL(ctx, "https://example.com/ended", ShortenOptions{TTL: time.Hour})
+	require.NoError(t, err)
+
+	svc.(*urlService).now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	_, err = svc.GetOriginalURL(ctx, url.ID)
+	assert.ErrorIs(t, err, ErrExpired)
+}
+
+func TestReaper(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryURLRepository()
+	svc := NewURLService(repo, "http://localhost:8080", WithReapInterval(10*time.Millisecond))
+
+	expiresAt := time.Now().Add(-time.Minute)
+	require.NoError(t, repo.Create(ctx, &model.URL{ID: "old", Original: "https://old.example", ExpiresAt: &expiresAt}))
+	kept, err := svc.ShortenURL(ctx, "https://kept.example", ShortenOptions{})
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		url, err := repo.FindByID(ctx, "old")
+		return err == nil && url == nil
+	}, time.Second, 10*time.Millisecond)
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	require.NoError(t, svc.Shutdown(shutdownCtx))
+
+	url, err := repo.FindByID(ctx, kept.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, url)
+}
+
+// blockingRepository отвечает только по истечении контекста, как зависший запрос к базе.
+type blockingRepository struct {
+	repository.URLRepository
+}
+
+func (r blockingRepository) Create(ctx context.Context, url *model.URL) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (r blockingRepository) FindByID(ctx context.Context, id string) (*model.URL, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// stalledRepository не применяет удаления, пока не закрыт release.
+type stalledRepository struct {
+	repository.URLRepository
+	release chan struct{}
+}
+
+func (r stalledRepository) MarkDeleted(ctx context.Context, items []model.URLDeletion) error {
+	<-r.release
+	return nil
+}
+
+func TestDeleteUserURLsOverloaded(t *testing.T) {
+	ctx := context.Background()
+	repo := stalledRepository{repository.NewInMemoryURLRepository(), make(chan struct{})}
+	svc := NewURLService(repo, "http://localhost:8080")
+
+	ids := make([]string, MaxDeleteIDs)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("id-%d", i)
+	}
+	var err error
+	for i := 0; i < 4 && err == nil; i++ {
+		err = svc.DeleteUserURLs(ctx, "user-1", ids)
+	}
+	assert.ErrorIs(t, err, ErrOverloaded)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.ErrorIs(t, svc.DeleteUserURLs(canceled, "user-1", ids), context.Canceled)
+
+	close(repo.release)
+	shutdownCtx, cancelShutdown := context.WithTimeout(ctx, 5*time.Second)
+	defer cancelShutdown()
+	require.NoError(t, svc.Shutdown(shutdownCtx))
+}
+
+func TestTimeouts(t *testing.T) {
+	ctx := context.Background()
+	svc := NewURLService(blockingRepository{repository.NewInMemoryURLRepository()}, "http://localhost:8080",
+		WithTimeouts(Timeouts{Lookup: 10 * time.Millisecond, Store: 10 * time.Millisecond}))
+	defer svc.Shutdown(context.Background())
+
+	_, err := svc.GetOriginalURL(ctx, "abc")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	_, err = svc.ShortenURL(ctx, "https://example.com", ShortenOptions{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Отмена запроса клиентом прерывает операцию и без собственного срока
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	noTimeouts := NewURLService(repository.NewInMemoryURLRepository(), "http://localhost:8080")
+	defer noTimeouts.Shutdown(context.Background())
+	_, err = noTimeouts.ShortenURL(canceled, "https://example.com", ShortenOptions{})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+type fakeClickRepository struct {
+	saved []model.Click
+}
+
+func (r *fakeClickRepository) SaveClicks(ctx context.Context, clicks []model.Click) error {
+	r.saved = append(r.saved, clicks...)
+	return nil
+}
+
+func (r *fakeClickRepository) ClickStats(ctx context.Context, urlID string, from, to time.Time, top int) (*model.ClickStats, error) {
+	return &model.ClickStats{URLID: urlID, From: from, To: to, TotalClicks: int64(len(r.saved))}, nil
+}
+
+func TestRecordClick(t *testing.T) {
+	ctx := context.Background()
+	clicks := &fakeClickRepository{}
+	svc := NewURLService(repository.NewInMemoryURLRepository(), "http://localhost:8080",
+		WithClicks(clicks, ClickOptions{IPKey: []byte("ip-key")}))
+
+	svc.RecordClick(ctx, "abc", ClickInfo{Referrer: "https://ref.example", UserAgent: "test", IP: "192.0.2.1"})
+	svc.RecordClick(ctx, "abc", ClickInfo{IP: "192.0.2.1"})
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	require.NoError(t, svc.Shutdown(shutdownCtx))
+
+	require.Len(t, clicks.saved, 2)
+	assert.Equal(t, "abc", clicks.saved[0].URLID)
+	assert.Equal(t, "https://ref.example", clicks.saved[0].Referrer)
+	assert.Equal(t, "test", clicks.saved[0].UserAgent)
+	assert.NotEmpty(t, clicks.saved[0].IPHash)
+	assert.Equal(t, clicks.saved[0].IPHash, clicks.saved[1].IPHash)
+
+	// После остановки события не принимаются и учитываются как отброшенные
+	svc.RecordClick(ctx, "abc", ClickInfo{})
+	assert.Equal(t, int64(1), svc.DroppedClicks())
+}
+
+func TestClickRecorderDropsWhenFull(t *testing.T) {
+	r := &clickRecorder{
+		ipKey: []byte("ip-key"),
+		queue: make(chan model.Click, 1),
+		done:  make(chan struct{}),
+	}
+
+	r.Record("a", ClickInfo{IP: "192.0.2.1"}, time.Now())
+	r.Record("b", ClickInfo{IP: "192.0.2.1"}, time.Now())
+	assert.Equal(t, int64(1), r.Dropped())
+
+	click := <-r.queue
+	assert.Equal(t, "a", click.URLID)
+	assert.Equal(t, r.hashIP("192.0.2.1"), click.IPHash)
+	assert.NotContains(t, click.IPHash, "192.0.2.1")
+	assert.NotEqual(t, click.IPHash, (&clickRecorder{ipKey: []byte("other")}).hashIP("192.0.2.1"))
+}
+
+func TestGetURLStats(t *testing.T) {
+	ctx := context.Background()
+	clicks := repository.NewInMemoryClickRepository()
+	svc := NewURLService(repository.NewInMemoryURLRepository(), "http://localhost:8080",
+		WithClicks(clicks, ClickOptions{}))
+	defer svc.Shutdown(context.Background())
+
+	own, err := svc.ShortenURL(ctx, "https://own.example", ShortenOptions{UserID: "user-1"})
+	require.NoError(t, err)
+
+	// Переход по прежней ссылке с тем же ID не относится к новой
+	require.NoError(t, clicks.SaveClicks(ctx, []model.Click{
+		{URLID: own.ID, Time: own.CreatedAt.Add(-48 * time.Hour)},
+		{URLID: own.ID, Time: own.CreatedAt},
+	}))
+	stats, err := svc.GetURLStats(ctx, "user-1", own.ID, StatsQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, own.CreatedAt, stats.From)
+	assert.EqualValues(t, 1, stats.TotalClicks)
+
+	stats, err = svc.GetURLStats(ctx, "user-1", own.ID, StatsQuery{To: own.CreatedAt.Add(-time.Hour)})
+	require.NoError(t, err)
+	assert.Zero(t, stats.TotalClicks)
+
+	_, err = svc.GetURLStats(ctx, "user-2", own.ID, StatsQuery{})
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = svc.GetURLStats(ctx, "user-1", "missing", StatsQuery{})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	now := time.Now()
+	for _, query := range []StatsQuery{
+		{From: now, To: now.Add(-time.Hour)},
+		{From: now.Add(-400 * 24 * time.Hour), To: now},
+		{Top: 1000},
+	} {
+		_, err = svc.GetURLStats(ctx, "user-1", own.ID, query)
+		assert.ErrorIs(t, err, ErrInvalidStatsQuery)
+	}
+
+	noClicks := NewURLService(repository.NewInMemoryURLRepository(), "http://localhost:8080")
+	_, err = noClicks.GetURLStats(ctx, "user-1", own.ID, StatsQuery{})
+	assert.ErrorIs(t, err, ErrStatsUnavailable)
+}
+
+func TestServiceMetrics(t *testing.T) {
+	ctx := context.Background()
+	m := metrics.New()
+	svc := NewURLService(repository.NewInMemoryURLRepository(), "http://localhost:8080", WithMetrics(m))
+
+	url, err := svc.ShortenURL(ctx, "https://example.com", ShortenOptions{})
+	require.NoError(t, err)
+	_, err = svc.ShortenURL(ctx, "https://example.com", ShortenOptions{})
+	require.Error(t, err)
+	_, err = svc.ShortenURL(ctx, "https://example.com/other", ShortenOptions{Alias: "x"})
+	require.ErrorIs(t, err, ErrInvalidAlias)
+
+	_, err = svc.GetOriginalURL(ctx, url.ID)
+	require.NoError(t, err)
+	_, err = svc.GetOriginalURL(ctx, "missing")
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	for _, line := range []string{
+		`shortener_shorten_total{result="success"} 1`,
+		`shortener_shorten_total{result="conflict"} 1`,
+		`shortener_shorten_total{result="invalid"} 1`,
+		`shortener_redirects_total{result="hit"} 1`,
+		`shortener_redirects_total{result="miss"} 1`,
+	} {
+		assert.Contains(t, body, line)
+	}
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"fmt"
+	"time"
+	"url-shortener/internal/model"
+)
+
+const (
+	defaultStatsRange = 30 * 24 * time.Hour
+	maxStatsRange     = 366 * 24 * time.Hour
+	defaultStatsTop   = 10
+	maxStatsTop       = 100
+)
+
+// StatsQuery задает интервал [From, To) и размер топов статистики.
+// Нулевые значения заменяются значениями по умолчанию: последние 30 дней и топ-10.
+type StatsQuery struct {
+	From time.Time
+	To   time.Time
+	Top  int
+}
+
+func (q *StatsQuery) normalize(now time.Time) error {
+	if q.To.IsZero() {
+		q.To = now
+	}
+	if q.From.IsZero() {
+		q.From = q.To.Add(-defaultStatsRange)
+	}
+	if q.Top == 0 {
+		q.Top = defaultStatsTop
+	}
+
+	switch {
+	case !q.From.Before(q.To):
+		return fmt.Errorf("%w: from must be before to", ErrInvalidStatsQuery)
+	case q.To.Sub(q.From) > maxStatsRange:
+		return fmt.Errorf("%w: range cannot exceed %d days", ErrInvalidStatsQuery, int(maxStatsRange.Hours()/24))
+	case q.Top < 1 || q.Top > maxStatsTop:
+		return fmt.Errorf("%w: top must be between 1 and %d", ErrInvalidStatsQuery, maxStatsTop)
+	}
+	return nil
+}
+
+// emptyStats — статистика за интервал, закончившийся до создания ссылки.
+func emptyStats(id string, query StatsQuery) *model.ClickStats {
+	return &model.ClickStats{
+		URLID:         id,
+		From:          query.From.UTC(),
+		To:            query.To.UTC(),
+		Daily:         []model.StatsPoint{},
+		Hourly:        []model.StatsPoint{},
+		TopReferrers:  []model.StatsEntry{},
+		TopUserAgents: []model.StatsEntry{},
+	}
+}
@@ -0,0 +1,71 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServerConfigSelfSigned(t *testing.T) {
+	cfg, err := ServerConfig("", "", []string{"localhost", "127.0.0.1"})
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), cfg.MinVersion)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	server.TLS = cfg
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	leaf, err := x509.ParseCertificate(cfg.Certificates[0].Certificate[0])
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: roots},
+		ForceAttemptHTTP2: true,
+	}}
+
+	// Сертификат выпущен для 127.0.0.1, поэтому проверка имени проходит
+	res, err := client.Get(server.URL)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, 2, res.ProtoMajor)
+}
+
+func TestServerConfigInvalidFiles(t *testing.T) {
+	_, err := ServerConfig("missing.crt", "missing.key", nil)
+	assert.Error(t, err)
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		port     string
+		target   string
+		host     string
+		location string
+	}{
+		{name: "custom port", port: "8443", target: "/abc?x=1", host: "example.com:8080", location: "https://example.com:8443/abc?x=1"},
+		{name: "default port", port: "443", target: "/abc", host: "example.com", location: "https://example.com/abc"},
+		{name: "ipv6 host", port: "443", target: "/", host: "[::1]:80", location: "https://[::1]/"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, test.target, nil)
+			req.Host = test.host
+			w := httptest.NewRecorder()
+			RedirectHandler(test.port).ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+			assert.Equal(t, test.location, w.Header().Get("Location"))
+		})
+	}
+}
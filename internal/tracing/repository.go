@@ -0,0 +1,34 @@
+package tracing
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"url-shortener/internal/repository"
+)
+
+// InstrumentRepository открывает клиентский спан URLRepository.<метод> на каждую операцию хранилища.
+func InstrumentRepository(repo repository.URLRepository) repository.URLRepository {
+	tracer := Tracer("repository")
+	return repository.Observe(repo, func(ctx context.Context, op repository.Operation) (context.Context, func(error)) {
+		ctx, span := tracer.Start(ctx, "URLRepository."+op.Name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(operationAttributes(op)...),
+		)
+		return ctx, func(err error) { End(span, &err) }
+	})
+}
+
+func operationAttributes(op repository.Operation) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{attribute.String("db.operation.name", op.Name)}
+	if op.URLID != "" {
+		attrs = append(attrs, attribute.String("url.id", op.URLID))
+	}
+	switch op.Name {
+	case "FindByUser":
+		attrs = append(attrs, attribute.Int("page.limit", op.Size))
+	case "CreateBatch", "MarkDeleted":
+		attrs = append(attrs, attribute.Int("batch.size", op.Size))
+	}
+	return attrs
+}
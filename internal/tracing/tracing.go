@@ -0,0 +1,145 @@
+// Package tracing настраивает OpenTelemetry: экспорт спанов, распространение контекста
+// по W3C traceparent и спаны HTTP-запросов и хранилища.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"os"
+)
+
+// Поддерживаемые экспортеры.
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+const serviceName = "url-shortener"
+
+type Options struct {
+	// Exporter — none, stdout или otlp
+	Exporter string
+	// File — файл для экспортера stdout; пустая строка — стандартный вывод
+	File string
+	// Endpoint — адрес OTLP/HTTP коллектора, например http://localhost:4318.
+	// Если не задан, используются переменные окружения OTEL_EXPORTER_OTLP_*
+	Endpoint string
+	// SampleRatio — доля трассировок, которые записываются, если решение не принято вызывающей стороной
+	SampleRatio float64
+}
+
+// Validate проверяет параметры трассировки.
+func (o Options) Validate() error {
+	switch o.Exporter {
+	case "", ExporterNone, ExporterStdout, ExporterOTLP:
+	default:
+		return fmt.Errorf("unknown trace exporter %q", o.Exporter)
+	}
+	if o.SampleRatio < 0 || o.SampleRatio > 1 {
+		return fmt.Errorf("trace sample ratio must be between 0 and 1")
+	}
+	return nil
+}
+
+// Init устанавливает глобальные TracerProvider и пропагатор. Возвращаемая функция
+// отправляет накопленные спаны и останавливает экспорт; ее нужно вызвать при остановке.
+// Без экспортера спаны не записываются, но traceparent по-прежнему принимается и передается дальше.
+func Init(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	exporter, closeOutput, err := newExporter(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if exporter == nil {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closeOutput != nil {
+			err = errors.Join(err, closeOutput())
+		}
+		return err
+	}, nil
+}
+
+func newExporter(ctx context.Context, opts Options) (sdktrace.SpanExporter, func() error, error) {
+	switch opts.Exporter {
+	case "", ExporterNone:
+		return nil, nil, nil
+	case ExporterStdout:
+		var (
+			out         io.Writer = os.Stdout
+			closeOutput func() error
+		)
+		if opts.File != "" {
+			f, err := os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to open trace file: %w", err)
+			}
+			out, closeOutput = f, f.Close
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(out))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create stdout trace exporter: %w", err)
+		}
+		return exporter, closeOutput, nil
+	case ExporterOTLP:
+		var clientOpts []otlptracehttp.Option
+		if opts.Endpoint != "" {
+			clientOpts = append(clientOpts, otlptracehttp.WithEndpointURL(opts.Endpoint))
+		}
+		exporter, err := otlptracehttp.New(ctx, clientOpts...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+		}
+		return exporter, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown trace exporter %q", opts.Exporter)
+	}
+}
+
+// Tracer возвращает трассировщик компонента из глобального TracerProvider.
+func Tracer(name string) trace.Tracer {
+	return otel.Tracer(serviceName + "/" + name)
+}
+
+// End завершает спан, отмечая в нем err. Вызывается через defer с указателем на именованную ошибку.
+func End(span trace.Span, err *error) {
+	if err != nil && *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
@@ -0,0 +1,102 @@
+package tracing
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"url-shortener/internal/model"
+	"url-shortener/internal/repository"
+)
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return recorder
+}
+
+func TestMiddlewarePropagatesTraceparent(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/:id", func(c *gin.Context) {
+		_, span := Tracer("test").Start(c.Request.Context(), "handler")
+		span.End()
+		c.Status(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	handlerSpan, serverSpan := spans[0], spans[1]
+
+	assert.Equal(t, "GET /:id", serverSpan.Name())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", serverSpan.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", serverSpan.Parent().SpanID().String())
+	assert.Equal(t, codes.Error, serverSpan.Status().Code)
+	assert.Equal(t, serverSpan.SpanContext().SpanID(), handlerSpan.Parent().SpanID())
+}
+
+func TestInstrumentRepository(t *testing.T) {
+	recorder := setupRecorder(t)
+	ctx := context.Background()
+
+	repo := InstrumentRepository(repository.NewInMemoryURLRepository())
+	_, pingable := repo.(repository.Pinger)
+	assert.False(t, pingable)
+
+	url := &model.URL{ID: "a", Original: "https://a.example"}
+	require.NoError(t, repo.Create(ctx, url))
+	require.Error(t, repo.Create(ctx, url))
+	_, err := repo.FindByID(ctx, "a")
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	assert.Equal(t, "URLRepository.Create", spans[0].Name())
+	// Конфликт — ожидаемый результат, а не сбой хранилища
+	assert.Equal(t, codes.Unset, spans[1].Status().Code)
+	assert.Equal(t, "URLRepository.FindByID", spans[2].Name())
+}
+
+func TestInit(t *testing.T) {
+	assert.Error(t, Options{Exporter: "jaeger"}.Validate())
+	assert.Error(t, Options{Exporter: ExporterStdout, SampleRatio: 2}.Validate())
+
+	prevProvider := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(prevProvider) })
+
+	path := filepath.Join(t.TempDir(), "traces.json")
+	shutdown, err := Init(context.Background(), Options{Exporter: ExporterStdout, File: path, SampleRatio: 1})
+	require.NoError(t, err)
+
+	_, span := Tracer("test").Start(context.Background(), "exported")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"Name":"exported"`)
+}
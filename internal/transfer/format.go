@@ -0,0 +1,187 @@
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+	"url-shortener/internal/model"
+)
+
+// Форматы выгрузки. В обоих одна ссылка — одна строка, поэтому выгрузку можно читать
+// и писать потоком и дописывать при продолжении.
+const (
+	FormatJSONL = "jsonl"
+	FormatCSV   = "csv"
+)
+
+var csvHeader = []string{"id", "original", "short", "user_id", "is_deleted", "created_at", "expires_at"}
+
+type Writer interface {
+	Write(url *model.URL) error
+	// Flush дописывает буферизованные записи; после него выгрузка содержит все записанные ссылки.
+	Flush() error
+}
+
+type Reader interface {
+	// Read возвращает следующую ссылку или io.EOF в конце выгрузки.
+	Read() (*model.URL, error)
+}
+
+// NewWriter создает запись выгрузки. header выключают при дописывании в существующий CSV.
+func NewWriter(format string, w io.Writer, header bool) (Writer, error) {
+	switch format {
+	case FormatJSONL:
+		bw := bufio.NewWriter(w)
+		return &jsonlWriter{w: bw, enc: json.NewEncoder(bw)}, nil
+	case FormatCSV:
+		cw := &csvWriter{w: csv.NewWriter(w)}
+		if header {
+			if err := cw.w.Write(csvHeader); err != nil {
+				return nil, err
+			}
+		}
+		return cw, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q (expected %s or %s)", format, FormatJSONL, FormatCSV)
+	}
+}
+
+func NewReader(format string, r io.Reader) (Reader, error) {
+	switch format {
+	case FormatJSONL:
+		return &jsonlReader{r: bufio.NewReader(r)}, nil
+	case FormatCSV:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = len(csvHeader)
+		cr.ReuseRecord = true
+		return &csvReader{r: cr}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q (expected %s or %s)", format, FormatJSONL, FormatCSV)
+	}
+}
+
+type jsonlWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (w *jsonlWriter) Write(url *model.URL) error {
+	return w.enc.Encode(url)
+}
+
+func (w *jsonlWriter) Flush() error {
+	return w.w.Flush()
+}
+
+type jsonlReader struct {
+	r    *bufio.Reader
+	line int
+}
+
+func (r *jsonlReader) Read() (*model.URL, error) {
+	for {
+		data, err := r.r.ReadBytes('\n')
+		if len(data) == 0 && errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		r.line++
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+
+		var url model.URL
+		if err := json.Unmarshal(data, &url); err != nil {
+			return nil, fmt.Errorf("line %d: %w", r.line, err)
+		}
+		if err := validate(&url); err != nil {
+			return nil, fmt.Errorf("line %d: %w", r.line, err)
+		}
+		return &url, nil
+	}
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (w *csvWriter) Write(url *model.URL) error {
+	expiresAt := ""
+	if url.ExpiresAt != nil {
+		expiresAt = url.ExpiresAt.Format(time.RFC3339Nano)
+	}
+	return w.w.Write([]string{
+		url.ID,
+		url.Original,
+		url.Short,
+		url.UserID,
+		strconv.FormatBool(url.Deleted),
+		url.CreatedAt.Format(time.RFC3339Nano),
+		expiresAt,
+	})
+}
+
+func (w *csvWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+type csvReader struct {
+	r *csv.Reader
+}
+
+func (r *csvReader) Read() (*model.URL, error) {
+	for {
+		record, err := r.r.Read()
+		if err != nil {
+			return nil, err
+		}
+		// Заголовок пропускается, где бы он ни встретился: при продолжении выгрузки он мог попасть в середину
+		if record[0] == csvHeader[0] && record[1] == csvHeader[1] {
+			continue
+		}
+		line, _ := r.r.FieldPos(0)
+
+		url := model.URL{
+			ID:       record[0],
+			Original: record[1],
+			Short:    record[2],
+			UserID:   record[3],
+		}
+		if url.Deleted, err = strconv.ParseBool(record[4]); err != nil {
+			return nil, fmt.Errorf("line %d: invalid is_deleted: %w", line, err)
+		}
+		if url.CreatedAt, err = time.Parse(time.RFC3339Nano, record[5]); err != nil {
+			return nil, fmt.Errorf("line %d: invalid created_at: %w", line, err)
+		}
+		if record[6] != "" {
+			expiresAt, err := time.Parse(time.RFC3339Nano, record[6])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid expires_at: %w", line, err)
+			}
+			url.ExpiresAt = &expiresAt
+		}
+		if err := validate(&url); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		return &url, nil
+	}
+}
+
+func validate(url *model.URL) error {
+	if url.ID == "" {
+		return errors.New("id is empty")
+	}
+	if url.Original == "" {
+		return errors.New("original URL is empty")
+	}
+	return nil
+}
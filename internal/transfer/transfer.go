@@ -0,0 +1,191 @@
+// Package transfer переносит ссылки между хранилищами через потоковую выгрузку
+// с сохранением ID, владельцев, пометок удаления и времени создания.
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"url-shortener/internal/model"
+	"url-shortener/internal/repository"
+)
+
+// Конфликт с ID, который в целевом хранилище занят другой ссылкой.
+const ProblemIDTaken = "id_taken"
+
+type ExportResult struct {
+	Count int
+	// Cursor — курсор последней выгруженной ссылки; с него выгрузку можно продолжить
+	Cursor string
+}
+
+// Export выгружает ссылки, включая удаленные, в порядке создания, начиная после курсора after.
+// При ошибке или отмене контекста выгруженное сбрасывается в w, а результат содержит курсор
+// последней записанной ссылки.
+func Export(ctx context.Context, src repository.Admin, after string, w Writer) (ExportResult, error) {
+	result := ExportResult{Cursor: after}
+	err := src.Scan(ctx, after, func(url *model.URL, cursor string) error {
+		if err := w.Write(url); err != nil {
+			return fmt.Errorf("failed to write %s: %w", url.ID, err)
+		}
+		result.Count++
+		result.Cursor = cursor
+		return nil
+	})
+	if flushErr := w.Flush(); flushErr != nil {
+		return result, errors.Join(err, fmt.Errorf("failed to flush export: %w", flushErr))
+	}
+	return result, err
+}
+
+type ImportOptions struct {
+	// DryRun только проверяет выгрузку на конфликты, ничего не записывая
+	DryRun bool
+	// BaseURL, если задан, заменяет адрес сервиса в коротких ссылках
+	BaseURL string
+}
+
+type ImportResult struct {
+	// Read — сколько ссылок прочитано из выгрузки
+	Read     int
+	Imported int
+	// Existing — ссылки, уже перенесенные прошлым запуском
+	Existing  int
+	Conflicts []repository.Problem
+}
+
+// Import записывает ссылки из выгрузки в dst. Уже перенесенные ссылки пропускаются, поэтому
+// прерванный импорт продолжается повторным запуском с той же выгрузкой. Ссылки, конфликтующие
+// с целевым хранилищем, не записываются и возвращаются в Conflicts.
+func Import(ctx context.Context, dst repository.URLRepository, r Reader, opts ImportOptions) (ImportResult, error) {
+	var result ImportResult
+	// При проверке ничего не пишется, поэтому конфликты внутри самой выгрузки отслеживаются здесь
+	var planned map[string]string
+	if opts.DryRun {
+		planned = make(map[string]string)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		url, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return result, nil
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to read export: %w", err)
+		}
+		result.Read++
+		if opts.BaseURL != "" {
+			url.Short = strings.TrimSuffix(opts.BaseURL, "/") + "/" + url.ID
+		}
+
+		conflict, existing, err := check(ctx, dst, url, planned)
+		if err != nil {
+			return result, err
+		}
+		switch {
+		case conflict != nil:
+			result.Conflicts = append(result.Conflicts, *conflict)
+		case existing != nil:
+			result.Existing++
+			// Ссылка могла быть перенесена до того, как ее удалили в источнике
+			if url.Deleted && !existing.Deleted && !opts.DryRun {
+				if err := markDeleted(ctx, dst, url); err != nil {
+					return result, err
+				}
+			}
+		case opts.DryRun:
+			planned["id:"+url.ID] = url.Original
+			if !url.Deleted {
+				planned["original:"+url.Original] = url.ID
+			}
+			result.Imported++
+		default:
+			conflict, err := create(ctx, dst, url)
+			if err != nil {
+				return result, err
+			}
+			if conflict != nil {
+				result.Conflicts = append(result.Conflicts, *conflict)
+				continue
+			}
+			result.Imported++
+		}
+	}
+}
+
+// check сверяет ссылку с целевым хранилищем. Ссылка с тем же ID и оригинальным URL считается
+// уже перенесенной и возвращается в existing.
+func check(ctx context.Context, dst repository.URLRepository, url *model.URL, planned map[string]string) (*repository.Problem, *model.URL, error) {
+	if original, ok := planned["id:"+url.ID]; ok {
+		if original == url.Original {
+			return nil, url, nil
+		}
+		return idTaken(url, original), nil, nil
+	}
+	if id, ok := planned["original:"+url.Original]; ok && !url.Deleted {
+		return duplicateOriginal(url, id), nil, nil
+	}
+
+	byID, err := dst.FindByID(ctx, url.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to look up %s: %w", url.ID, err)
+	}
+	if byID != nil {
+		if byID.Original == url.Original {
+			return nil, byID, nil
+		}
+		return idTaken(url, byID.Original), nil, nil
+	}
+	// Удаленная ссылка переносится удаленной и действующей ссылке на тот же URL не мешает
+	if url.Deleted {
+		return nil, nil, nil
+	}
+
+	byOriginal, err := dst.FindByOriginalURL(ctx, url.Original)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to look up %s: %w", url.Original, err)
+	}
+	// Удаленная или истекшая ссылка не мешает сократить URL заново
+	if byOriginal != nil && !byOriginal.Deleted && !byOriginal.Expired(time.Now()) {
+		return duplicateOriginal(url, byOriginal.ID), nil, nil
+	}
+	return nil, nil, nil
+}
+
+// create записывает ссылку. Конфликты, появившиеся после проверки, возвращаются как нарушения.
+func create(ctx context.Context, dst repository.URLRepository, url *model.URL) (*repository.Problem, error) {
+	err := dst.Create(ctx, url)
+	var conflict *repository.ErrConflict
+	switch {
+	case errors.As(err, &conflict):
+		return duplicateOriginal(url, conflict.URL.ID), nil
+	case errors.Is(err, repository.ErrIDExists):
+		return &repository.Problem{Kind: ProblemIDTaken, ID: url.ID, Detail: "ID is already taken"}, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to import %s: %w", url.ID, err)
+	}
+	return nil, nil
+}
+
+func markDeleted(ctx context.Context, dst repository.URLRepository, url *model.URL) error {
+	if err := dst.MarkDeleted(ctx, []model.URLDeletion{{UserID: url.UserID, ID: url.ID}}); err != nil {
+		return fmt.Errorf("failed to mark %s deleted: %w", url.ID, err)
+	}
+	return nil
+}
+
+func idTaken(url *model.URL, original string) *repository.Problem {
+	return &repository.Problem{Kind: ProblemIDTaken, ID: url.ID,
+		Detail: fmt.Sprintf("ID is taken by %s, skipping %s", original, url.Original)}
+}
+
+func duplicateOriginal(url *model.URL, id string) *repository.Problem {
+	return &repository.Problem{Kind: repository.ProblemDuplicateOriginal, ID: url.ID,
+		Detail: fmt.Sprintf("%s is already shortened as %s", url.Original, id)}
+}
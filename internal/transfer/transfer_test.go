@@ -0,0 +1,220 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"url-shortener/internal/model"
+	"url-shortener/internal/repository"
+)
+
+func seed(t *testing.T) *repository.InMemoryURLRepository {
+	t.Helper()
+	ctx := context.Background()
+	repo := repository.NewInMemoryURLRepository()
+	created := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC)
+	expires := created.Add(24 * time.Hour)
+	urls := []*model.URL{
+		{ID: "a", Original: "https://a.example", Short: "http://old/a", UserID: "user-1", CreatedAt: created},
+		{ID: "b", Original: "https://b.example/?q=1,2", Short: "http://old/b", UserID: "user-1", CreatedAt: created.Add(time.Minute), ExpiresAt: &expires},
+		{ID: "c", Original: "https://c.example", Short: "http://old/c", CreatedAt: created.Add(2 * time.Minute)},
+	}
+	for _, url := range urls {
+		require.NoError(t, repo.Create(ctx, url))
+	}
+	require.NoError(t, repo.MarkDeleted(ctx, []model.URLDeletion{{UserID: "user-1", ID: "a"}}))
+	return repo
+}
+
+func scanAll(t *testing.T, repo repository.Admin) []*model.URL {
+	t.Helper()
+	var urls []*model.URL
+	require.NoError(t, repo.Scan(context.Background(), "", func(url *model.URL, _ string) error {
+		urls = append(urls, url)
+		return nil
+	}))
+	return urls
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	src := seed(t)
+
+	for _, format := range []string{FormatJSONL, FormatCSV} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(format, &buf, true)
+			require.NoError(t, err)
+			exported, err := Export(ctx, src, "", w)
+			require.NoError(t, err)
+			assert.Equal(t, 3, exported.Count)
+
+			dst, err := repository.NewFileURLRepository(filepath.Join(t.TempDir(), "urls.json"), repository.FileOptions{})
+			require.NoError(t, err)
+			defer dst.Close()
+
+			r, err := NewReader(format, bytes.NewReader(buf.Bytes()))
+			require.NoError(t, err)
+			imported, err := Import(ctx, dst, r, ImportOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, ImportResult{Read: 3, Imported: 3}, imported)
+
+			want, got := scanAll(t, src), scanAll(t, dst)
+			require.Len(t, got, len(want))
+			for i := range want {
+				assert.Equal(t, want[i].ID, got[i].ID)
+				assert.Equal(t, want[i].Original, got[i].Original)
+				assert.Equal(t, want[i].Short, got[i].Short)
+				assert.Equal(t, want[i].UserID, got[i].UserID)
+				assert.Equal(t, want[i].Deleted, got[i].Deleted)
+				assert.True(t, want[i].CreatedAt.Equal(got[i].CreatedAt))
+				if want[i].ExpiresAt == nil {
+					assert.Nil(t, got[i].ExpiresAt)
+				} else {
+					require.NotNil(t, got[i].ExpiresAt)
+					assert.True(t, want[i].ExpiresAt.Equal(*got[i].ExpiresAt))
+				}
+			}
+
+			// Повторный импорт ничего не меняет
+			r, err = NewReader(format, bytes.NewReader(buf.Bytes()))
+			require.NoError(t, err)
+			imported, err = Import(ctx, dst, r, ImportOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, ImportResult{Read: 3, Existing: 3}, imported)
+		})
+	}
+}
+
+func TestExportResume(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	src := seed(t)
+
+	// Прерываем выгрузку после первой ссылки
+	var buf bytes.Buffer
+	w, err := NewWriter(FormatCSV, &buf, true)
+	require.NoError(t, err)
+	first, err := Export(ctx, src, "", &cancelAfter{Writer: w, n: 1, cancel: cancel})
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, first.Count)
+
+	w, err = NewWriter(FormatCSV, &buf, false)
+	require.NoError(t, err)
+	rest, err := Export(context.Background(), src, first.Cursor, w)
+	require.NoError(t, err)
+	assert.Equal(t, 2, rest.Count)
+
+	r, err := NewReader(FormatCSV, &buf)
+	require.NoError(t, err)
+	dst := repository.NewInMemoryURLRepository()
+	imported, err := Import(context.Background(), dst, r, ImportOptions{BaseURL: "https://new.example/"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, imported.Imported)
+
+	url, err := dst.FindByID(context.Background(), "b")
+	require.NoError(t, err)
+	require.NotNil(t, url)
+	assert.Equal(t, "https://new.example/b", url.Short)
+}
+
+// cancelAfter отменяет контекст после n записанных ссылок.
+type cancelAfter struct {
+	Writer
+	n      int
+	cancel context.CancelFunc
+}
+
+func (w *cancelAfter) Write(url *model.URL) error {
+	if err := w.Writer.Write(url); err != nil {
+		return err
+	}
+	if w.n--; w.n == 0 {
+		w.cancel()
+	}
+	return nil
+}
+
+func TestImportConflicts(t *testing.T) {
+	ctx := context.Background()
+	dst := repository.NewInMemoryURLRepository()
+	require.NoError(t, dst.Create(ctx, &model.URL{ID: "a", Original: "https://other.example", Short: "http://s/a"}))
+	require.NoError(t, dst.Create(ctx, &model.URL{ID: "x", Original: "https://c.example", Short: "http://s/x"}))
+
+	dump := `{"id":"a","original":"https://a.example","short":"http://old/a","created_at":"2024-01-01T00:00:00Z"}
+{"id":"b","original":"https://b.example","short":"http://old/b","created_at":"2024-01-01T00:00:00Z"}
+{"id":"c","original":"https://c.example","short":"http://old/c","created_at":"2024-01-01T00:00:00Z"}
+{"id":"d","original":"https://b.example","short":"http://old/d","created_at":"2024-01-01T00:00:00Z"}
+`
+	// Проверка находит и конфликты с хранилищем, и конфликты внутри выгрузки, ничего не записывая
+	r, err := NewReader(FormatJSONL, strings.NewReader(dump))
+	require.NoError(t, err)
+	result, err := Import(ctx, dst, r, ImportOptions{DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, 4, result.Read)
+	assert.Equal(t, 1, result.Imported)
+	require.Len(t, result.Conflicts, 3)
+	assert.Equal(t, ProblemIDTaken, result.Conflicts[0].Kind)
+	assert.Equal(t, repository.ProblemDuplicateOriginal, result.Conflicts[1].Kind)
+	assert.Equal(t, "c", result.Conflicts[1].ID)
+	assert.Equal(t, "d", result.Conflicts[2].ID)
+	b, err := dst.FindByID(ctx, "b")
+	require.NoError(t, err)
+	assert.Nil(t, b)
+
+	r, err = NewReader(FormatJSONL, strings.NewReader(dump))
+	require.NoError(t, err)
+	result, err = Import(ctx, dst, r, ImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Imported)
+	assert.Len(t, result.Conflicts, 3)
+	b, err = dst.FindByID(ctx, "b")
+	require.NoError(t, err)
+	assert.NotNil(t, b)
+}
+
+func TestImportDeletedWithActiveOriginal(t *testing.T) {
+	ctx := context.Background()
+	dst := repository.NewInMemoryURLRepository()
+	require.NoError(t, dst.Create(ctx, &model.URL{ID: "x", Original: "https://a.example", Short: "http://s/x"}))
+
+	dump := `{"id":"a","original":"https://a.example","short":"http://old/a","is_deleted":true,"created_at":"2024-01-01T00:00:00Z"}
+`
+	for _, dryRun := range []bool{true, false} {
+		r, err := NewReader(FormatJSONL, strings.NewReader(dump))
+		require.NoError(t, err)
+		result, err := Import(ctx, dst, r, ImportOptions{DryRun: dryRun})
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Imported)
+		assert.Empty(t, result.Conflicts)
+	}
+
+	a, err := dst.FindByID(ctx, "a")
+	require.NoError(t, err)
+	require.NotNil(t, a)
+	assert.True(t, a.Deleted)
+	// Действующая ссылка на тот же URL остается найденной по оригиналу
+	found, err := dst.FindByOriginalURL(ctx, "https://a.example")
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, "x", found.ID)
+}
+
+func TestReaderErrors(t *testing.T) {
+	_, err := NewReader("xml", strings.NewReader(""))
+	assert.Error(t, err)
+
+	r, err := NewReader(FormatJSONL, strings.NewReader("\n{\"id\":\"a\"}\n"))
+	require.NoError(t, err)
+	_, err = r.Read()
+	assert.ErrorContains(t, err, "line 2")
+
+	r, err = NewReader(FormatCSV, strings.NewReader("a,https://a.example,,,maybe,2024-01-01T00:00:00Z,\n"))
+	require.NoError(t, err)
+	_, err = r.Read()
+	assert.ErrorContains(t, err, "invalid is_deleted")
+}
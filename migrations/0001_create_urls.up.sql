@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS urls (
+    id           TEXT PRIMARY KEY,
+    original_url TEXT NOT NULL UNIQUE,
+    short_url    TEXT NOT NULL
+);
@@ -0,0 +1 @@
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS user_id TEXT NOT NULL DEFAULT '';
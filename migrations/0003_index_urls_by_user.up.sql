@@ -0,0 +1,3 @@
+-- seq задает порядок ссылок пользователя и служит курсором пагинации
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS seq BIGSERIAL;
+CREATE INDEX IF NOT EXISTS urls_user_id_seq_idx ON urls (user_id, seq);
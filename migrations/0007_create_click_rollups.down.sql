@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS click_user_agents;
+DROP TABLE IF EXISTS click_referrers;
+DROP TABLE IF EXISTS click_visitors;
+DROP TABLE IF EXISTS click_hourly;
@@ -0,0 +1,52 @@
+CREATE TABLE IF NOT EXISTS click_hourly (
+    url_id TEXT        NOT NULL,
+    hour   TIMESTAMPTZ NOT NULL,
+    clicks BIGINT      NOT NULL,
+    PRIMARY KEY (url_id, hour)
+);
+
+CREATE TABLE IF NOT EXISTS click_visitors (
+    url_id  TEXT        NOT NULL,
+    day     TIMESTAMPTZ NOT NULL,
+    ip_hash TEXT        NOT NULL,
+    PRIMARY KEY (url_id, day, ip_hash)
+);
+
+CREATE TABLE IF NOT EXISTS click_referrers (
+    url_id   TEXT        NOT NULL,
+    day      TIMESTAMPTZ NOT NULL,
+    referrer TEXT        NOT NULL,
+    clicks   BIGINT      NOT NULL,
+    PRIMARY KEY (url_id, day, referrer)
+);
+
+CREATE TABLE IF NOT EXISTS click_user_agents (
+    url_id     TEXT        NOT NULL,
+    day        TIMESTAMPTZ NOT NULL,
+    user_agent TEXT        NOT NULL,
+    clicks     BIGINT      NOT NULL,
+    PRIMARY KEY (url_id, day, user_agent)
+);
+
+-- Заполняем агрегаты по уже записанным переходам
+INSERT INTO click_hourly (url_id, hour, clicks)
+SELECT url_id, date_trunc('hour', clicked_at), count(*)
+FROM clicks GROUP BY 1, 2
+ON CONFLICT DO NOTHING;
+
+INSERT INTO click_visitors (url_id, day, ip_hash)
+SELECT DISTINCT url_id, date_trunc('day', clicked_at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC', ip_hash
+FROM clicks WHERE ip_hash <> ''
+ON CONFLICT DO NOTHING;
+
+INSERT INTO click_referrers (url_id, day, referrer, clicks)
+SELECT url_id, date_trunc('day', clicked_at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC',
+       COALESCE(substring(referrer FROM '^[A-Za-z][A-Za-z0-9+.-]*://(?:[^@/?#]*@)?([^/?#]+)'), left(referrer, 256)),
+       count(*)
+FROM clicks GROUP BY 1, 2, 3
+ON CONFLICT DO NOTHING;
+
+INSERT INTO click_user_agents (url_id, day, user_agent, clicks)
+SELECT url_id, date_trunc('day', clicked_at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC', left(user_agent, 256), count(*)
+FROM clicks GROUP BY 1, 2, 3
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,4 @@
+-- Удаленная ссылка не мешает сократить тот же URL заново: уникальны только действующие
+ALTER TABLE urls DROP CONSTRAINT IF EXISTS urls_original_url_key;
+CREATE UNIQUE INDEX IF NOT EXISTS urls_original_url_active_idx ON urls (original_url) WHERE NOT is_deleted;
+CREATE INDEX IF NOT EXISTS urls_original_url_idx ON urls (original_url);
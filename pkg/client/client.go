@@ -0,0 +1,511 @@
+// Package client — клиент HTTP API сервиса сокращения ссылок.
+//
+// Клиент хранит cookie аутентификации, поэтому все вызовы одного клиента выполняются
+// от имени одного пользователя. Токен можно сохранить через Token и передать в WithToken.
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// AuthCookieName — cookie, в которой сервер выдает токен пользователя.
+	AuthCookieName = "auth_token"
+
+	nextCursorHeader = "X-Next-Cursor"
+	// gzipThreshold — тела запросов больше этого размера сжимаются
+	gzipThreshold = 1024
+)
+
+// Client — клиент HTTP API. Безопасен для одновременного использования.
+type Client struct {
+	baseURL *url.URL
+	http    *http.Client
+	retry   RetryPolicy
+	// token из WithToken записывается в cookie jar после всех опций, когда jar уже окончательный
+	token string
+}
+
+// RetryPolicy задает повторы запросов, завершившихся ответом 5xx, 429 или сетевой ошибкой.
+// POST повторяется только после 429 и 503 или если запрос не успел уйти на сервер:
+// иначе повтор мог бы создать ссылку второй раз.
+type RetryPolicy struct {
+	// MaxAttempts — сколько всего попыток делать; 1 отключает повторы
+	MaxAttempts int
+	// BaseDelay удваивается с каждой попыткой, но не превышает MaxDelay.
+	// Retry-After из ответа тоже ограничивается MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// DefaultRetryPolicy используется, если не задан WithRetry.
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: 2 * time.Second}
+
+type Option func(*Client) error
+
+// WithHTTPClient задает HTTP-клиент. Клиент копируется: ему назначается cookie jar, если его нет,
+// и отключается переход по редиректам, чтобы Resolve мог прочитать Location.
+func WithHTTPClient(c *http.Client) Option {
+	return func(cl *Client) error {
+		copied := *c
+		if copied.Jar == nil {
+			copied.Jar = cl.http.Jar
+		}
+		cl.http = &copied
+		return nil
+	}
+}
+
+// WithRetry задает политику повторов.
+func WithRetry(policy RetryPolicy) Option {
+	return func(cl *Client) error {
+		if policy.MaxAttempts < 1 {
+			return fmt.Errorf("retry attempts must be positive")
+		}
+		cl.retry = policy
+		return nil
+	}
+}
+
+// WithToken задает токен пользователя, полученный ранее через Token.
+func WithToken(token string) Option {
+	return func(cl *Client) error {
+		cl.token = token
+		return nil
+	}
+}
+
+// New создает клиент для сервиса по адресу baseURL, например http://localhost:8080.
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q: expected absolute http or https URL", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		baseURL: u,
+		http:    &http.Client{Jar: jar},
+		retry:   DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	if c.token != "" {
+		c.http.Jar.SetCookies(c.baseURL, []*http.Cookie{{Name: AuthCookieName, Value: c.token, Path: "/"}})
+	}
+	c.http.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return c, nil
+}
+
+// Token возвращает токен пользователя, выданный сервером, или пустую строку, если его еще нет.
+func (c *Client) Token() string {
+	for _, cookie := range c.http.Jar.Cookies(c.baseURL) {
+		if cookie.Name == AuthCookieName {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// Shorten сокращает URL через POST / с телом text/plain. Если URL уже был сокращен,
+// возвращается существующая ссылка вместе с *ConflictError.
+func (c *Client) Shorten(ctx context.Context, originalURL string) (string, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/", "text/plain", []byte(originalURL))
+	if err != nil {
+		return "", err
+	}
+	short := strings.TrimSpace(string(resp.body))
+	switch resp.status {
+	case http.StatusCreated:
+		return short, nil
+	case http.StatusConflict:
+		return short, &ConflictError{ShortURL: short}
+	default:
+		return "", resp.err()
+	}
+}
+
+// ShortenRequest — параметры ShortenJSON.
+type ShortenRequest struct {
+	URL string
+	// Alias задает ID короткой ссылки вместо случайного
+	Alias string
+	// ExpiresAt или TTL ограничивают срок действия ссылки; задать можно только одно из них
+	ExpiresAt *time.Time
+	TTL       time.Duration
+}
+
+// ShortenJSON сокращает URL через POST /api/shorten. Занятый алиас возвращается как *APIError
+// с кодом 409, уже сокращенный URL — как существующая ссылка вместе с *ConflictError.
+func (c *Client) ShortenJSON(ctx context.Context, req ShortenRequest) (string, error) {
+	payload := struct {
+		URL       string     `json:"url"`
+		Alias     string     `json:"alias,omitempty"`
+		ExpiresAt *time.Time `json:"expires_at,omitempty"`
+		TTL       string     `json:"ttl,omitempty"`
+	}{URL: req.URL, Alias: req.Alias, ExpiresAt: req.ExpiresAt}
+	if req.TTL != 0 {
+		payload.TTL = req.TTL.String()
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, "/api/shorten", "application/json", body)
+	if err != nil {
+		return "", err
+	}
+	if resp.status != http.StatusCreated && !resp.isShortenConflict() {
+		return "", resp.err()
+	}
+	var result struct {
+		Result string `json:"result"`
+	}
+	if err := json.Unmarshal(resp.body, &result); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if resp.status == http.StatusConflict {
+		return result.Result, &ConflictError{ShortURL: result.Result}
+	}
+	return result.Result, nil
+}
+
+type BatchItem struct {
+	CorrelationID string `json:"correlation_id"`
+	OriginalURL   string `json:"original_url"`
+}
+
+type BatchResult struct {
+	CorrelationID string `json:"correlation_id"`
+	ShortURL      string `json:"short_url"`
+}
+
+// ShortenBatch сокращает пачку URL. Если часть из них уже была сокращена, возвращаются
+// все ссылки вместе с *ConflictError без ShortURL.
+func (c *Client) ShortenBatch(ctx context.Context, items []BatchItem) ([]BatchResult, error) {
+	body, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(ctx, http.MethodPost, "/api/shorten/batch", "application/json", body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.status != http.StatusCreated && resp.status != http.StatusConflict {
+		return nil, resp.err()
+	}
+	var results []BatchResult
+	if err := json.Unmarshal(resp.body, &results); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if resp.status == http.StatusConflict {
+		return results, &ConflictError{}
+	}
+	return results, nil
+}
+
+// Resolve возвращает исходный URL короткой ссылки с идентификатором id, не переходя по нему.
+// Сервер учитывает вызов как переход. Удаленные и истекшие ссылки возвращают ErrGone.
+func (c *Client) Resolve(ctx context.Context, id string) (string, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/"+url.PathEscape(id), "", nil)
+	if err != nil {
+		return "", err
+	}
+	if resp.status != http.StatusTemporaryRedirect {
+		return "", resp.err()
+	}
+	return resp.header.Get("Location"), nil
+}
+
+type UserURL struct {
+	ShortURL    string `json:"short_url"`
+	OriginalURL string `json:"original_url"`
+}
+
+// ListOptions — параметры страницы ListURLs; нулевые значения оставляют выбор серверу.
+type ListOptions struct {
+	Limit  int
+	Cursor string
+}
+
+// ListURLs возвращает страницу ссылок пользователя и курсор следующей страницы,
+// пустой на последней. Без токена пользователя возвращает ErrUnauthorized.
+func (c *Client) ListURLs(ctx context.Context, opts ListOptions) ([]UserURL, string, error) {
+	query := url.Values{}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Cursor != "" {
+		query.Set("cursor", opts.Cursor)
+	}
+	path := "/api/user/urls"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, path, "", nil)
+	if err != nil {
+		return nil, "", err
+	}
+	switch resp.status {
+	case http.StatusNoContent:
+		return nil, "", nil
+	case http.StatusOK:
+		var urls []UserURL
+		if err := json.Unmarshal(resp.body, &urls); err != nil {
+			return nil, "", fmt.Errorf("failed to decode response: %w", err)
+		}
+		return urls, resp.header.Get(nextCursorHeader), nil
+	default:
+		return nil, "", resp.err()
+	}
+}
+
+// DeleteURLs ставит ссылки пользователя в очередь на удаление. Чужие и несуществующие ID
+// сервер молча пропускает.
+func (c *Client) DeleteURLs(ctx context.Context, ids []string) error {
+	body, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(ctx, http.MethodDelete, "/api/user/urls", "application/json", body)
+	if err != nil {
+		return err
+	}
+	if resp.status != http.StatusAccepted {
+		return resp.err()
+	}
+	return nil
+}
+
+type response struct {
+	status     int
+	header     http.Header
+	body       []byte
+	retryAfter time.Duration
+}
+
+// isShortenConflict отличает уже сокращенный URL от занятого алиаса: у первого в ответе есть ссылка.
+func (r *response) isShortenConflict() bool {
+	return r.status == http.StatusConflict && bytes.Contains(r.body, []byte(`"result"`))
+}
+
+func (r *response) err() error {
+	apiErr := &APIError{StatusCode: r.status}
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(r.body, &body) == nil && body.Error != "" {
+		apiErr.Message = body.Error
+	} else {
+		apiErr.Message = strings.TrimSpace(string(r.body))
+	}
+	return apiErr
+}
+
+// do выполняет запрос с повторами. Тело хранится целиком, чтобы каждую попытку отправлять заново.
+func (c *Client) do(ctx context.Context, method, path, contentType string, body []byte) (*response, error) {
+	idempotent := method != http.MethodPost
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		resp, sent, err := c.attempt(ctx, method, path, contentType, body)
+		if err == nil && !retryable(resp.status, idempotent) {
+			return resp, nil
+		}
+		if err != nil {
+			// Запрос мог дойти до сервера, и повтор POST создал бы ссылку второй раз
+			if ctx.Err() != nil || sent && !idempotent {
+				return nil, err
+			}
+			lastErr = err
+		} else {
+			lastErr = resp.err()
+		}
+		if attempt >= c.retry.MaxAttempts {
+			if err == nil {
+				// Последний ответ возвращается как есть, его ошибку сформирует вызывающий метод
+				return resp, nil
+			}
+			return nil, lastErr
+		}
+
+		delay := c.backoff(attempt)
+		if err == nil && resp.retryAfter > 0 {
+			delay = min(resp.retryAfter, c.retry.MaxDelay)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt выполняет одну попытку и сообщает, был ли запрос отправлен серверу.
+func (c *Client) attempt(ctx context.Context, method, path, contentType string, body []byte) (*response, bool, error) {
+	var reader io.Reader
+	compressed := false
+	if len(body) > gzipThreshold {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(body); err != nil {
+			return nil, false, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, false, err
+		}
+		reader = &buf
+		compressed = true
+	} else if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	// Транспорт вызывает WroteRequest, как только начал писать запрос, даже если запись не удалась
+	var sent atomic.Bool
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		WroteRequest: func(httptrace.WroteRequestInfo) { sent.Store(true) },
+	})
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL.String()+path, reader)
+	if err != nil {
+		return nil, false, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	// Заголовок выставлен явно, поэтому распаковывать ответ транспорт не будет — делаем это сами
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, sent.Load(), err
+	}
+	defer res.Body.Close()
+
+	var bodyReader io.Reader = res.Body
+	if strings.Contains(res.Header.Get("Content-Encoding"), "gzip") {
+		zr, err := gzip.NewReader(res.Body)
+		if err != nil {
+			return nil, true, fmt.Errorf("failed to decompress response: %w", err)
+		}
+		defer zr.Close()
+		bodyReader = zr
+	}
+	data, err := io.ReadAll(bodyReader)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	resp := &response{status: res.StatusCode, header: res.Header, body: data}
+	if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		resp.retryAfter = time.Duration(seconds) * time.Second
+	}
+	return resp, true, nil
+}
+
+// retryable — ответы, после которых запрос имеет смысл повторить. 501 означает,
+// что функция на сервере выключена, и повтор ничего не изменит. Неидемпотентный запрос
+// повторяется только после 429 и 503: с ними сервер запрос точно не выполнил.
+func retryable(status int, idempotent bool) bool {
+	if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+		return true
+	}
+	return idempotent && status >= http.StatusInternalServerError && status != http.StatusNotImplemented
+}
+
+// backoff возвращает экспоненциальную задержку с разбросом, чтобы клиенты не повторяли запросы одновременно.
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.retry.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > c.retry.MaxDelay {
+		delay = c.retry.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// Ошибки, с которыми можно сравнивать через errors.Is.
+var (
+	ErrConflict     = errors.New("conflict")
+	ErrNotFound     = errors.New("not found")
+	ErrGone         = errors.New("gone")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrRateLimited  = errors.New("rate limited")
+)
+
+// APIError — ответ сервера с неожиданным кодом.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("shortener API: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Unwrap сопоставляет код ответа с ошибками пакета.
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusGone:
+		return ErrGone
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	default:
+		return nil
+	}
+}
+
+// ConflictError означает, что URL уже был сокращен. ShortURL — существующая ссылка.
+type ConflictError struct {
+	ShortURL string
+}
+
+func (e *ConflictError) Error() string {
+	if e.ShortURL == "" {
+		return "shortener API: URL already shortened"
+	}
+	return "shortener API: URL already shortened as " + e.ShortURL
+}
+
+func (e *ConflictError) Unwrap() error {
+	return ErrConflict
+}
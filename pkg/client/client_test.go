@@ -0,0 +1,325 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"url-shortener/internal/handler"
+	"url-shortener/internal/middleware"
+	"url-shortener/internal/repository"
+	"url-shortener/internal/service"
+)
+
+// testServer поднимает настоящие обработчики с сервисом на репозитории в памяти.
+// Если задан wrap, он оборачивает роутер, чтобы подменять ответы сервера.
+func testServer(t *testing.T, wrap func(http.Handler) http.Handler) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	var h http.Handler
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	svc := service.NewURLService(repository.NewInMemoryURLRepository(), srv.URL)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = svc.Shutdown(ctx)
+	})
+	handlers := handler.NewHandler(svc)
+
+	router := gin.New()
+	router.Use(middleware.GzipMiddleware())
+	router.Use(middleware.AuthMiddleware(middleware.NewAuthenticator([]byte("test-secret"))))
+	router.POST("/", handlers.ShortenURL)
+	router.GET("/:id", handlers.GetOriginalURL)
+	router.POST("/api/shorten", handlers.ShortenJSONUrl)
+	router.POST("/api/shorten/batch", handlers.ShortenBatch)
+	router.GET("/api/user/urls", middleware.RequireAuth(), handlers.GetUserURLs)
+	router.DELETE("/api/user/urls", middleware.RequireAuth(), handlers.DeleteUserURLs)
+
+	h = router
+	if wrap != nil {
+		h = wrap(router)
+	}
+	return srv
+}
+
+func newClient(t *testing.T, baseURL string, opts ...Option) *Client {
+	t.Helper()
+	opts = append([]Option{WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond})}, opts...)
+	c, err := New(baseURL, opts...)
+	require.NoError(t, err)
+	return c
+}
+
+func TestNew(t *testing.T) {
+	for _, baseURL := range []string{"", "localhost:8080", "ftp://example.com", "http://"} {
+		_, err := New(baseURL)
+		assert.Error(t, err, baseURL)
+	}
+	_, err := New("http://localhost:8080", WithRetry(RetryPolicy{}))
+	assert.Error(t, err)
+
+	c, err := New("http://localhost:8080/")
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080", c.baseURL.String())
+
+	// Токен попадает в jar клиента из WithHTTPClient, даже если задан раньше него
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	c, err = New("http://localhost:8080", WithToken("token"), WithHTTPClient(&http.Client{Jar: jar}))
+	require.NoError(t, err)
+	assert.Equal(t, "token", c.Token())
+	assert.Len(t, jar.Cookies(c.baseURL), 1)
+}
+
+func TestShorten(t *testing.T) {
+	srv := testServer(t, nil)
+	c := newClient(t, srv.URL)
+	ctx := context.Background()
+
+	short, err := c.Shorten(ctx, "https://example.com/plain")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(short, srv.URL+"/"), short)
+
+	again, err := c.Shorten(ctx, "https://example.com/plain")
+	var conflict *ConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.Equal(t, short, again)
+	assert.Equal(t, short, conflict.ShortURL)
+
+	original, err := c.Resolve(ctx, strings.TrimPrefix(short, srv.URL+"/"))
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/plain", original)
+
+	_, err = c.Shorten(ctx, " ")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, "URL cannot be empty", apiErr.Message)
+}
+
+func TestShortenJSON(t *testing.T) {
+	srv := testServer(t, nil)
+	c := newClient(t, srv.URL)
+	ctx := context.Background()
+
+	short, err := c.ShortenJSON(ctx, ShortenRequest{URL: "https://example.com/json", Alias: "my-alias", TTL: time.Hour})
+	require.NoError(t, err)
+	assert.Equal(t, srv.URL+"/my-alias", short)
+
+	existing, err := c.ShortenJSON(ctx, ShortenRequest{URL: "https://example.com/json"})
+	var conflict *ConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, short, existing)
+
+	_, err = c.ShortenJSON(ctx, ShortenRequest{URL: "https://example.com/other", Alias: "my-alias"})
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.False(t, errors.As(err, &conflict))
+	assert.Equal(t, http.StatusConflict, apiErr.StatusCode)
+
+	expired := time.Now().Add(-time.Hour)
+	_, err = c.ShortenJSON(ctx, ShortenRequest{URL: "https://example.com/expired", ExpiresAt: &expired})
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+}
+
+func TestResolve(t *testing.T) {
+	srv := testServer(t, nil)
+	c := newClient(t, srv.URL)
+
+	_, err := c.Resolve(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestUserURLs(t *testing.T) {
+	var compressed atomic.Bool
+	srv := testServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Content-Encoding") == "gzip" {
+				compressed.Store(true)
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	c := newClient(t, srv.URL)
+	ctx := context.Background()
+
+	_, _, err := c.ListURLs(ctx, ListOptions{})
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	require.NotEmpty(t, c.Token())
+
+	// Пачка больше порога сжатия уходит на сервер в gzip
+	items := make([]BatchItem, 50)
+	for i := range items {
+		items[i] = BatchItem{CorrelationID: fmt.Sprint(i), OriginalURL: fmt.Sprintf("https://example.com/batch/%d", i)}
+	}
+	results, err := c.ShortenBatch(ctx, items)
+	require.NoError(t, err)
+	require.Len(t, results, len(items))
+	assert.True(t, compressed.Load())
+	assert.Equal(t, "0", results[0].CorrelationID)
+
+	var urls []UserURL
+	cursor := ""
+	for {
+		page, next, err := c.ListURLs(ctx, ListOptions{Limit: 20, Cursor: cursor})
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(page), 20)
+		urls = append(urls, page...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	assert.Len(t, urls, len(items))
+
+	// Тот же пользователь из другого клиента по сохраненному токену
+	other := newClient(t, srv.URL, WithToken(c.Token()))
+	page, _, err := other.ListURLs(ctx, ListOptions{Limit: 1})
+	require.NoError(t, err)
+	assert.Len(t, page, 1)
+
+	stranger := newClient(t, srv.URL)
+	_, err = stranger.Shorten(ctx, "https://example.com/stranger")
+	require.NoError(t, err)
+	page, next, err := stranger.ListURLs(ctx, ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, page, 1)
+	assert.Empty(t, next)
+
+	id := strings.TrimPrefix(results[0].ShortURL, srv.URL+"/")
+	require.NoError(t, c.DeleteURLs(ctx, []string{id}))
+	assert.Eventually(t, func() bool {
+		_, err := c.Resolve(ctx, id)
+		return errors.Is(err, ErrGone)
+	}, 5*time.Second, 50*time.Millisecond)
+
+	_, err = c.ShortenBatch(ctx, items[1:2])
+	var conflict *ConflictError
+	assert.ErrorAs(t, err, &conflict)
+}
+
+// dropConnection — условный статус, при котором сервер закрывает соединение, не отвечая.
+const dropConnection = -1
+
+func TestRetry(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		failures []int
+		attempts int
+	)
+	failWith := func(statuses ...int) {
+		mu.Lock()
+		defer mu.Unlock()
+		failures = statuses
+		attempts = 0
+	}
+	srv := testServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			attempts++
+			var status int
+			if len(failures) > 0 {
+				status, failures = failures[0], failures[1:]
+			}
+			mu.Unlock()
+
+			switch status {
+			case 0:
+				next.ServeHTTP(w, r)
+			case dropConnection:
+				conn, _, err := w.(http.Hijacker).Hijack()
+				if err == nil {
+					conn.Close()
+				}
+			case http.StatusTooManyRequests:
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, `{"error":"Too many requests"}`, status)
+			default:
+				http.Error(w, `{"error":"Service unavailable"}`, status)
+			}
+		})
+	})
+	c := newClient(t, srv.URL)
+	ctx := context.Background()
+
+	failWith(http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	_, err := c.Shorten(ctx, "https://example.com/retry")
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+
+	failWith(http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	_, err = c.Shorten(ctx, "https://example.com/exhausted")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	assert.Equal(t, "Service unavailable", apiErr.Message)
+	assert.Equal(t, 3, attempts)
+
+	failWith(http.StatusNotImplemented)
+	_, err = c.Shorten(ctx, "https://example.com/not-implemented")
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+
+	// POST мог выполниться на сервере, поэтому после 502 и обрыва соединения не повторяется
+	failWith(http.StatusBadGateway)
+	_, err = c.Shorten(ctx, "https://example.com/bad-gateway")
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+	assert.Equal(t, 1, attempts)
+
+	failWith(dropConnection)
+	_, err = c.Shorten(ctx, "https://example.com/dropped")
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+
+	// Идемпотентные запросы повторяются после любых сбоев
+	failWith(http.StatusBadGateway, dropConnection)
+	_, _, err = c.ListURLs(ctx, ListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+
+	// Retry-After ограничен MaxDelay
+	failWith(http.StatusTooManyRequests)
+	start := time.Now()
+	_, err = c.Shorten(ctx, "https://example.com/capped")
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+
+	patient := newClient(t, srv.URL, WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Second}))
+	failWith(http.StatusTooManyRequests)
+	start = time.Now()
+	_, err = patient.Shorten(ctx, "https://example.com/limited")
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+
+	// Отмена контекста прерывает ожидание перед повтором
+	failWith(http.StatusTooManyRequests)
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = patient.Shorten(ctx, "https://example.com/canceled")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	failWith(http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests)
+	noRetry := newClient(t, srv.URL, WithRetry(RetryPolicy{MaxAttempts: 1}))
+	_, err = noRetry.Shorten(context.Background(), "https://example.com/no-retry")
+	assert.ErrorIs(t, err, ErrRateLimited)
+}